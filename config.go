@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,12 +13,29 @@ type LarkApp struct {
 	LarkSecret  string
 	LarkBaseURL string
 	LarkWebURL  string
+
+	RecordCacheTTL         time.Duration // 记录解析缓存时长
+	RecordNegativeCacheTTL time.Duration // 记录未找到时的缓存时长
+
+	LarkHTTPTimeout time.Duration // 飞书 API 请求超时时间
+
+	LarkLinkSecret         string        // 签名短链接密钥
+	LarkLinkTTL            time.Duration // 签名短链接默认有效期
+	LarkLinkAuthCollection string        // 除超级管理员外允许生成签名短链接的认证集合
+
+	LarkVerificationToken string        // 飞书事件订阅的 Verification Token
+	LarkEncryptKey        string        // 飞书事件订阅的 Encrypt Key
+	LarkEventRetention    time.Duration // 飞书事件审计记录的保留时长
 }
 
 // GitLabConfig GitLab相关配置
 type GitLabConfig struct {
 	WebhookSecret string // GitLab webhook secret token
+	SigningToken  string // GitLab webhook signing token
 	BaseURL       string // GitLab实例的基础URL
+	APIToken      string // 调用GitLab REST API使用的访问令牌
+
+	DeliveryRetention time.Duration // webhook投递去重记录的保留时长
 }
 
 // LoadConfig 从环境变量加载配置
@@ -32,6 +50,19 @@ func LoadConfig() *LarkApp {
 		LarkSecret:  os.Getenv("LARK_APP_SECRET"),
 		LarkBaseURL: getEnvOrDefault("LARK_BASE_URL", "https://open.feishu.cn"),
 		LarkWebURL:  getEnvOrDefault("LARK_WEB_URL", ""),
+
+		RecordCacheTTL:         getDurationEnvOrDefault("LARK_RECORD_CACHE_TTL", time.Hour),
+		RecordNegativeCacheTTL: getDurationEnvOrDefault("LARK_RECORD_CACHE_NEGATIVE_TTL", 5*time.Minute),
+
+		LarkHTTPTimeout: getDurationEnvOrDefault("LARK_HTTP_TIMEOUT", 10*time.Second),
+
+		LarkLinkSecret:         os.Getenv("LARK_LINK_SECRET"),
+		LarkLinkTTL:            getDurationEnvOrDefault("LARK_LINK_TTL", 15*time.Minute),
+		LarkLinkAuthCollection: os.Getenv("LARK_LINK_AUTH_COLLECTION"),
+
+		LarkVerificationToken: os.Getenv("LARK_VERIFICATION_TOKEN"),
+		LarkEncryptKey:        os.Getenv("LARK_ENCRYPT_KEY"),
+		LarkEventRetention:    getDurationEnvOrDefault("LARK_EVENT_RETENTION", 30*24*time.Hour),
 	}
 }
 
@@ -44,7 +75,11 @@ func LoadGitLabConfig() *GitLabConfig {
 
 	return &GitLabConfig{
 		WebhookSecret: os.Getenv("GITLAB_WEBHOOK_SECRET"),
+		SigningToken:  os.Getenv("GITLAB_WEBHOOK_SIGNING_TOKEN"),
 		BaseURL:       getEnvOrDefault("GITLAB_BASE_URL", "https://gitlab.com"),
+		APIToken:      os.Getenv("GITLAB_API_TOKEN"),
+
+		DeliveryRetention: getDurationEnvOrDefault("GITLAB_DELIVERY_RETENTION", 7*24*time.Hour),
 	}
 }
 
@@ -55,3 +90,18 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getDurationEnvOrDefault 获取时长类型的环境变量（如 "10m"、"1h"），不存在或格式错误时返回默认值
+func getDurationEnvOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: Invalid duration for %s: %v, using default %s", key, err, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
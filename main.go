@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 	"gitlab.yogorobot.com/sre/lark-base-mapping/middlewares"
@@ -22,8 +23,19 @@ func main() {
 
 	// 加载GitLab配置
 	gitlabConfig := LoadGitLabConfig()
-	log.Printf("Loaded GitLab config: BaseURL=%s, WebhookSecret configured=%t",
-		gitlabConfig.BaseURL, gitlabConfig.WebhookSecret != "")
+	log.Printf("Loaded GitLab config: BaseURL=%s, WebhookSecret configured=%t, SigningToken configured=%t",
+		gitlabConfig.BaseURL, gitlabConfig.WebhookSecret != "", gitlabConfig.SigningToken != "")
+
+	// 注册需要在中间件日志中掩码的密钥
+	middlewares.RegisterSecrets(
+		config.LarkSecret,
+		config.LarkLinkSecret,
+		config.LarkVerificationToken,
+		config.LarkEncryptKey,
+		gitlabConfig.WebhookSecret,
+		gitlabConfig.SigningToken,
+		gitlabConfig.APIToken,
+	)
 
 	// 创建飞书中间件配置，使用NewLarkConfig函数
 	larkConfig := middlewares.NewLarkConfig(
@@ -31,12 +43,24 @@ func main() {
 		config.LarkSecret,
 		config.LarkBaseURL,
 		config.LarkWebURL,
+		middlewares.WithLarkTimeout(config.LarkHTTPTimeout),
 	)
+	larkConfig.CacheTTL = config.RecordCacheTTL
+	larkConfig.NegativeCacheTTL = config.RecordNegativeCacheTTL
+	larkConfig.LinkSecret = config.LarkLinkSecret
+	larkConfig.LinkTTL = config.LarkLinkTTL
+	larkConfig.VerificationToken = config.LarkVerificationToken
+	larkConfig.EncryptKey = config.LarkEncryptKey
+	if larkConfig.VerificationToken == "" && larkConfig.EncryptKey == "" {
+		log.Printf("LARK_VERIFICATION_TOKEN and LARK_ENCRYPT_KEY are not configured, /webhook/lark will reject all events")
+	}
 
 	// 创建GitLab中间件配置
 	gitlabMiddlewareConfig := &middlewares.GitLabConfig{
 		WebhookSecret: gitlabConfig.WebhookSecret,
+		SigningToken:  gitlabConfig.SigningToken,
 		BaseURL:       gitlabConfig.BaseURL,
+		APIToken:      gitlabConfig.APIToken,
 	}
 
 	isGoRun := strings.HasPrefix(os.Args[0], os.TempDir())
@@ -47,19 +71,85 @@ func main() {
 		Automigrate: isGoRun,
 	})
 
+	// 注册记录解析缓存的失效钩子和清理任务
+	router.BindLarkRecordCacheHooks(app)
+
+	// 注册飞书字段映射的校验钩子
+	router.BindLarkFieldMappingHooks(app)
+
+	// 注册通知规则的校验钩子
+	router.BindNotificationRuleHooks(app)
+
+	// 注册用户映射的钩子
+	router.BindUserMappingHooks(app)
+
+	// 注册GitLab webhook投递记录的清理任务
+	middlewares.BindGitLabDeliveryCleanup(app, gitlabConfig.DeliveryRetention)
+
+	// 注册飞书事件审计记录的清理任务
+	middlewares.BindLarkEventCleanup(app, config.LarkEventRetention)
+
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		// 注册飞书路由并绑定飞书中间件
 		se.Router.GET("/base/{baseID}/{tableID}/{recordID}", router.LarkBaseTable).BindFunc(
 			middlewares.LarkAuth(larkConfig),
+			middlewares.LarkAuthRequired(larkConfig),
 		)
 		se.Router.GET("/base/{baseID}/{tableID}", router.LarkBaseTable).BindFunc(
 			middlewares.LarkAuth(larkConfig),
+			middlewares.LarkAuthRequired(larkConfig),
 		)
 
+		// 注册签名短链接生成路由（仅超级管理员或 LARK_LINK_AUTH_COLLECTION 指定集合的认证用户）
+		linkAuthCollections := []string{core.CollectionNameSuperusers}
+		if config.LarkLinkAuthCollection != "" {
+			linkAuthCollections = append(linkAuthCollections, config.LarkLinkAuthCollection)
+		}
+		se.Router.POST("/api/lark/links", router.LarkSignedLink).BindFunc(
+			middlewares.LarkAuth(larkConfig),
+		).Bind(apis.RequireAuth(linkAuthCollections...))
+
+		// 注册记录解析缓存清除路由（仅超级管理员）
+		se.Router.DELETE("/api/lark/cache", router.LarkRecordCachePurge).Bind(
+			apis.RequireSuperuserAuth(),
+		)
+
+		// 注册多维表格事件订阅路由（仅超级管理员）
+		se.Router.POST("/api/lark/bases/{baseID}/subscribe", router.LarkBaseSubscribe).BindFunc(
+			middlewares.LarkAuth(larkConfig),
+		).Bind(apis.RequireSuperuserAuth())
+
+		// 注册GitLab发布和DORA指标查询路由（需要 PocketBase 认证）
+		se.Router.GET("/api/gitlab/projects/{projectID}/releases/latest", router.GitLabLatestRelease).Bind(
+			apis.RequireAuth(),
+		)
+		se.Router.GET("/api/gitlab/projects/{projectID}/tags/compare", router.GitLabTagCompare).Bind(
+			apis.RequireAuth(),
+		)
+		se.Router.GET("/api/gitlab/projects/{projectID}/dora", router.GitLabDORAMetrics).Bind(
+			apis.RequireAuth(),
+		)
+
+		// 注册GitLab与飞书用户映射同步路由（仅超级管理员）
+		se.Router.POST("/api/gitlab/users/sync", router.GitLabUserMappingSync).BindFunc(
+			middlewares.LarkAuth(larkConfig),
+			middlewares.GitLabAPI(gitlabMiddlewareConfig),
+		).Bind(apis.RequireSuperuserAuth())
+
 		// 注册GitLab webhook路由并绑定GitLab和飞书中间件
 		se.Router.POST("/webhook/gitlab", router.GitLabWebhook).BindFunc(
+			middlewares.GitLabSignatureVerify(gitlabMiddlewareConfig),
 			middlewares.GitLabWebhook(gitlabMiddlewareConfig),
+			middlewares.GitLabDeliveryDedupe(),
+			middlewares.LarkAuth(larkConfig),
+		)
+
+		// 注册飞书事件订阅回调路由，验证签名并解密后记录审计，多维表格修改通过GitLab API回写
+		se.Router.POST("/webhook/lark", router.LarkWebhook).BindFunc(
+			middlewares.LarkEventVerify(larkConfig),
+			middlewares.LarkEventAudit(),
 			middlewares.LarkAuth(larkConfig),
+			middlewares.GitLabAPI(gitlabMiddlewareConfig),
 		)
 
 		return se.Next()
@@ -1,11 +1,18 @@
 package middlewares
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
-	"encoding/hex"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -13,35 +20,27 @@ import (
 // GitLabConfig 存储GitLab配置
 type GitLabConfig struct {
 	WebhookSecret string
+	SigningToken  string
 	BaseURL       string
+	APIToken      string
 }
 
 // GitLabWebhook 创建GitLab webhook中间件
+//
+// 请求认证由 GitLabSignatureVerify 完成，需绑定在该中间件之前。
 func GitLabWebhook(config *GitLabConfig) func(e *core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
-		// 验证GitLab webhook secret token（如果配置了）
-		if config.WebhookSecret != "" {
-			token := e.Request.Header.Get("X-Gitlab-Token")
-			if token != config.WebhookSecret {
-				e.App.Logger().Warn("GitLab webhook token verification failed",
-					"receivedToken", token,
-					"expectedToken", config.WebhookSecret,
-				)
-				return e.UnauthorizedError("Invalid GitLab webhook token", nil)
-			}
-		}
-
 		// 验证Content-Type
 		contentType := e.Request.Header.Get("Content-Type")
 		if contentType != "application/json" {
-			e.App.Logger().Warn("Invalid Content-Type for GitLab webhook",
+			Logger(e.App).Warn("Invalid Content-Type for GitLab webhook",
 				"contentType", contentType,
 			)
 			return e.BadRequestError("Invalid Content-Type, expected application/json", nil)
 		}
 
 		// 记录webhook信息
-		e.App.Logger().Info("GitLab webhook received",
+		Logger(e.App).Info("GitLab webhook received",
 			"event", e.Request.Header.Get("X-Gitlab-Event"),
 			"source", e.Request.Header.Get("X-Gitlab-Instance"),
 			"userAgent", e.Request.Header.Get("User-Agent"),
@@ -55,41 +54,62 @@ func GitLabWebhook(config *GitLabConfig) func(e *core.RequestEvent) error {
 	}
 }
 
-// GitLabSignatureVerify 创建GitLab webhook签名验证中间件
-func GitLabSignatureVerify(config *GitLabConfig) func(e *core.RequestEvent) error {
+// GitLabAPI 创建GitLab API中间件，将GitLab配置注入请求上下文，供非GitLab webhook的路由调用GitLab API
+func GitLabAPI(config *GitLabConfig) func(e *core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
-		// 如果没有配置webhook secret，跳过签名验证
-		if config.WebhookSecret == "" {
-			e.App.Logger().Debug("GitLab webhook secret not configured, skipping signature verification")
-			ctx := context.WithValue(e.Request.Context(), "gitlab_config", config)
-			e.Request = e.Request.WithContext(ctx)
-			return e.Next()
-		}
+		ctx := context.WithValue(e.Request.Context(), "gitlab_config", config)
+		e.Request = e.Request.WithContext(ctx)
 
-		// 获取GitLab签名
-		signature := e.Request.Header.Get("X-Gitlab-Event-UUID")
-		if signature == "" {
-			e.App.Logger().Warn("Missing X-Gitlab-Event-UUID header")
-			return e.BadRequestError("Missing GitLab event UUID", nil)
-		}
+		return e.Next()
+	}
+}
+
+// gitlabSignatureTolerance 签名时间戳允许的最大偏差，防止重放
+const gitlabSignatureTolerance = 5 * time.Minute
+
+var (
+	ErrGitLabTokenMissing     = errors.New("missing X-Gitlab-Token header")
+	ErrGitLabTokenMismatch    = errors.New("X-Gitlab-Token mismatch")
+	ErrGitLabSignatureMissing = errors.New("missing webhook-signature header")
+	ErrGitLabSignatureInvalid = errors.New("webhook signature mismatch")
+	ErrGitLabTimestampInvalid = errors.New("invalid or expired webhook-timestamp header")
+)
 
-		// 读取请求体
+// GitLabSignatureVerify 创建GitLab webhook签名验证中间件
+//
+// 从 gitlab_webhook_secrets 解析到密钥（或配置了全局 WebhookSecret）时，
+// 使用常量时间比较校验 X-Gitlab-Token；
+// 配置了 SigningToken 时校验 GitLab 签名令牌的 webhook-signature 请求头。
+// 两者都配置时需要同时通过。验证失败返回 401，请求体会被还原供后续处理器读取。
+func GitLabSignatureVerify(config *GitLabConfig) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		// 读取请求体并还原，供后续处理器使用
 		body, err := io.ReadAll(e.Request.Body)
 		if err != nil {
-			e.App.Logger().Error("Failed to read request body", "error", err)
+			Logger(e.App).Error("Failed to read request body", "error", err)
 			return e.BadRequestError("Failed to read request body", err)
 		}
+		e.Request.Body = io.NopCloser(bytes.NewReader(body))
 
-		// 计算期望的签名
-		h := hmac.New(sha256.New, []byte(config.WebhookSecret))
-		h.Write(body)
-		expectedSignature := hex.EncodeToString(h.Sum(nil))
-
-		// 验证签名（这里简化处理，实际GitLab可能使用不同的签名方式）
-		e.App.Logger().Debug("GitLab signature verification",
-			"signature", signature,
-			"expectedSignature", expectedSignature,
-		)
+		secrets, err := ResolveGitLabSecrets(e.App, e.Request.Header, body, config.WebhookSecret)
+		if err != nil {
+			Logger(e.App).Warn("GitLab webhook scope rejected",
+				"reason", err.Error(),
+				"event", e.Request.Header.Get("X-Gitlab-Event"),
+				"eventUUID", e.Request.Header.Get("X-Gitlab-Event-UUID"),
+			)
+			return e.UnauthorizedError("Conflicting project or group in GitLab webhook payload", nil)
+		}
+		if err := VerifyGitLabRequest(e.Request.Header, body, secrets, config.SigningToken, time.Now()); err != nil {
+			Logger(e.App).Warn("GitLab webhook verification failed",
+				"reason", err.Error(),
+				"receivedFingerprint", Fingerprint(e.Request.Header.Get("X-Gitlab-Token")),
+				"expectedFingerprints", secretFingerprints(secrets),
+				"event", e.Request.Header.Get("X-Gitlab-Event"),
+				"eventUUID", e.Request.Header.Get("X-Gitlab-Event-UUID"),
+			)
+			return e.UnauthorizedError("Invalid GitLab webhook token", nil)
+		}
 
 		// 将配置信息添加到请求上下文中
 		ctx := context.WithValue(e.Request.Context(), "gitlab_config", config)
@@ -99,6 +119,94 @@ func GitLabSignatureVerify(config *GitLabConfig) func(e *core.RequestEvent) erro
 	}
 }
 
+// secretFingerprints 返回密钥列表的指纹，日志中只记录指纹，不传入原始密钥
+func secretFingerprints(secrets []string) []string {
+	fingerprints := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		fingerprints = append(fingerprints, Fingerprint(secret))
+	}
+	return fingerprints
+}
+
+// VerifyGitLabRequest 校验GitLab webhook请求的 secret token 和签名
+//
+// secrets 为当前请求可接受的密钥列表（轮换期间可能有多个），为空时跳过 token 校验。
+func VerifyGitLabRequest(header http.Header, body []byte, secrets []string, signingToken string, now time.Time) error {
+	if len(secrets) > 0 {
+		if err := VerifyGitLabToken(header.Get("X-Gitlab-Token"), secrets...); err != nil {
+			return err
+		}
+	}
+
+	if signingToken != "" {
+		if err := VerifyGitLabSignature(header, body, signingToken, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifyGitLabToken 使用常量时间比较校验 X-Gitlab-Token，与任一有效密钥匹配即通过
+func VerifyGitLabToken(received string, expected ...string) error {
+	if received == "" {
+		return ErrGitLabTokenMissing
+	}
+
+	matched := 0
+	for _, secret := range expected {
+		matched |= subtle.ConstantTimeCompare([]byte(received), []byte(secret))
+	}
+	if matched != 1 {
+		return ErrGitLabTokenMismatch
+	}
+	return nil
+}
+
+// VerifyGitLabSignature 校验GitLab签名令牌生成的签名
+//
+// 签名遵循 Standard Webhooks 规范：webhook-signature 请求头包含一个或多个
+// 以空格分隔的 "v1,<base64>" 签名，签名内容为 "{webhook-id}.{webhook-timestamp}.{body}" 的 HMAC-SHA256。
+func VerifyGitLabSignature(header http.Header, body []byte, signingToken string, now time.Time) error {
+	signatures := header.Get("Webhook-Signature")
+	if signatures == "" {
+		return ErrGitLabSignatureMissing
+	}
+
+	timestamp := header.Get("Webhook-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrGitLabTimestampInvalid
+	}
+	if delta := now.Sub(time.Unix(ts, 0)); delta > gitlabSignatureTolerance || delta < -gitlabSignatureTolerance {
+		return ErrGitLabTimestampInvalid
+	}
+
+	key := []byte(signingToken)
+	if encoded, ok := strings.CutPrefix(signingToken, "whsec_"); ok {
+		if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			key = decoded
+		}
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(header.Get("Webhook-Id") + "." + timestamp + "."))
+	h.Write(body)
+	expected := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	for _, signature := range strings.Fields(signatures) {
+		version, value, ok := strings.Cut(signature, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		if hmac.Equal([]byte(value), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrGitLabSignatureInvalid
+}
+
 // GetGitLabConfigFromContext 从上下文中获取GitLab配置
 func GetGitLabConfigFromContext(ctx context.Context) (*GitLabConfig, bool) {
 	config, ok := ctx.Value("gitlab_config").(*GitLabConfig)
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const gitlabDeliveriesCollection = "gitlab_webhook_deliveries"
+
+// gitlabDeliveryStaleAfter 处理中状态超过该时长视为处理中断，允许重新处理
+const gitlabDeliveryStaleAfter = 10 * time.Minute
+
+// GitLabDeliveryDedupe 创建GitLab webhook投递去重中间件
+//
+// 以 X-Gitlab-Event-UUID 为键记录每次投递，已成功处理或正在处理的重复投递
+// 直接返回成功而不再交给处理器；处理失败的投递允许 GitLab 重试时重新处理。
+func GitLabDeliveryDedupe() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		eventUUID := e.Request.Header.Get("X-Gitlab-Event-UUID")
+		if eventUUID == "" {
+			return e.Next()
+		}
+
+		collection, err := e.App.FindCachedCollectionByNameOrId(gitlabDeliveriesCollection)
+		if err != nil {
+			Logger(e.App).Warn("gitlab_webhook_deliveries collection not found", "error", err)
+			return e.Next()
+		}
+
+		now := types.NowDateTime()
+		delivery, err := e.App.FindFirstRecordByData(collection, "event_uuid", eventUUID)
+		if err == nil {
+			status := delivery.GetString("status")
+			stale := delivery.GetDateTime("received_at").Time().Add(gitlabDeliveryStaleAfter).Before(now.Time())
+			if status == "processed" || status == "processing" && !stale {
+				Logger(e.App).Info("Duplicate GitLab webhook delivery ignored",
+					"eventUUID", eventUUID,
+					"hookType", delivery.GetString("hook_type"),
+					"status", status,
+				)
+				return e.JSON(http.StatusOK, map[string]interface{}{
+					"status":     "success",
+					"message":    "Duplicate delivery ignored",
+					"event_uuid": eventUUID,
+				})
+			}
+		} else {
+			delivery = core.NewRecord(collection)
+			delivery.Set("event_uuid", eventUUID)
+		}
+
+		delivery.Set("hook_type", e.Request.Header.Get("X-Gitlab-Event"))
+		delivery.Set("received_at", now)
+		delivery.Set("status", "processing")
+		delivery.Set("error", "")
+		delivery.Set("attempts", delivery.GetInt("attempts")+1)
+
+		if err := e.App.Save(delivery); err != nil {
+			// 唯一索引冲突说明并发的重复投递已被记录
+			if delivery.IsNew() {
+				Logger(e.App).Info("Concurrent GitLab webhook delivery ignored", "eventUUID", eventUUID, "error", err)
+				return e.JSON(http.StatusOK, map[string]interface{}{
+					"status":     "success",
+					"message":    "Duplicate delivery ignored",
+					"event_uuid": eventUUID,
+				})
+			}
+			Logger(e.App).Error("Failed to save GitLab webhook delivery", "eventUUID", eventUUID, "error", err)
+		}
+
+		handlerErr := e.Next()
+
+		delivery.Set("processed_at", types.NowDateTime())
+		delivery.Set("response_status", e.Status())
+		if handlerErr != nil {
+			delivery.Set("status", "failed")
+			delivery.Set("error", handlerErr.Error())
+		} else {
+			delivery.Set("status", "processed")
+		}
+
+		if err := e.App.Save(delivery); err != nil {
+			Logger(e.App).Error("Failed to update GitLab webhook delivery", "eventUUID", eventUUID, "error", err)
+		}
+
+		return handlerErr
+	}
+}
+
+// BindGitLabDeliveryCleanup 注册定时任务，清理超过保留时长的投递记录
+func BindGitLabDeliveryCleanup(app core.App, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	app.Cron().MustAdd("gitlab_webhook_deliveries_cleanup", "30 * * * *", func() {
+		cutoff := types.NowDateTime().Add(-retention)
+
+		records, err := app.FindRecordsByFilter(
+			gitlabDeliveriesCollection,
+			"received_at < {:cutoff}",
+			"",
+			0,
+			0,
+			dbx.Params{"cutoff": cutoff.String()},
+		)
+		if err != nil {
+			Logger(app).Error("Failed to load expired GitLab webhook deliveries", "error", err)
+			return
+		}
+
+		for _, record := range records {
+			if err := app.Delete(record); err != nil {
+				Logger(app).Error("Failed to delete expired GitLab webhook delivery", "error", err, "recordID", record.Id)
+				return
+			}
+		}
+
+		if len(records) > 0 {
+			Logger(app).Info("Expired GitLab webhook deliveries cleaned up", "deleted", len(records), "retention", retention.String())
+		}
+	})
+}
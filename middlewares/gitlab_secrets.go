@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ErrGitLabScopeConflict 负载中的 project.id 与 project_id（或 group.group_id 与 group_id）不一致，
+// 密钥按其中一个解析、处理器按另一个存储时可借用其他项目的密钥注入事件
+var ErrGitLabScopeConflict = errors.New("conflicting project or group ids in webhook payload")
+
+// gitlabHookScope webhook 负载中用于确定密钥范围的字段
+type gitlabHookScope struct {
+	ProjectID int `json:"project_id"`
+	GroupID   int `json:"group_id"`
+	Project   struct {
+		ID                int    `json:"id"`
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	Group struct {
+		GroupID  int    `json:"group_id"`
+		FullPath string `json:"full_path"`
+	} `json:"group"`
+}
+
+// ResolveGitLabSecrets 根据负载中的项目、组和 X-Gitlab-Instance 解析应使用的 webhook 密钥
+//
+// 按项目、组、实例的顺序查找 gitlab_webhook_secrets 中有效的密钥，使用最具体的一级；
+// 都没有配置时回退到全局密钥 fallback。返回空列表表示未配置任何密钥。
+// 负载中同时包含 project.id 和 project_id（或 group.group_id 和 group_id）且不一致时返回 ErrGitLabScopeConflict。
+func ResolveGitLabSecrets(app core.App, header http.Header, body []byte, fallback string) ([]string, error) {
+	var scope gitlabHookScope
+	if err := json.Unmarshal(body, &scope); err != nil {
+		Logger(app).Debug("Failed to parse GitLab webhook scope", "error", err)
+	}
+
+	if scope.Project.ID > 0 && scope.ProjectID > 0 && scope.Project.ID != scope.ProjectID ||
+		scope.Group.GroupID > 0 && scope.GroupID > 0 && scope.Group.GroupID != scope.GroupID {
+		return nil, ErrGitLabScopeConflict
+	}
+
+	projectID := scope.Project.ID
+	if projectID == 0 {
+		projectID = scope.ProjectID
+	}
+	groupID := scope.Group.GroupID
+	if groupID == 0 {
+		groupID = scope.GroupID
+	}
+	projectPath := scope.Project.PathWithNamespace
+
+	records, err := app.FindRecordsByFilter(
+		"gitlab_webhook_secrets",
+		"active = true && (expires_at = '' || expires_at > {:now})",
+		"",
+		0,
+		0,
+		dbx.Params{"now": types.NowDateTime().String()},
+	)
+	if err != nil {
+		Logger(app).Debug("Failed to load GitLab webhook secrets", "error", err)
+		records = nil
+	}
+
+	var projectSecrets, groupSecrets, instanceSecrets []string
+	instance := strings.TrimRight(header.Get("X-Gitlab-Instance"), "/")
+
+	for _, record := range records {
+		scopeID := strings.TrimSpace(record.GetString("scope_id"))
+		secret := record.GetString("secret")
+		RegisterSecrets(secret)
+
+		switch record.GetString("scope") {
+		case "project":
+			if projectID > 0 && scopeID == strconv.Itoa(projectID) ||
+				projectPath != "" && scopeID == projectPath {
+				projectSecrets = append(projectSecrets, secret)
+			}
+		case "group":
+			if groupID > 0 && scopeID == strconv.Itoa(groupID) ||
+				scopeID != "" && scopeID == scope.Group.FullPath ||
+				scopeID != "" && strings.HasPrefix(projectPath, strings.TrimRight(scopeID, "/")+"/") {
+				groupSecrets = append(groupSecrets, secret)
+			}
+		case "instance":
+			if scopeID == "" || strings.TrimRight(scopeID, "/") == instance {
+				instanceSecrets = append(instanceSecrets, secret)
+			}
+		}
+	}
+
+	switch {
+	case len(projectSecrets) > 0:
+		return projectSecrets, nil
+	case len(groupSecrets) > 0:
+		return groupSecrets, nil
+	case len(instanceSecrets) > 0:
+		return instanceSecrets, nil
+	case fallback != "":
+		return []string{fallback}, nil
+	default:
+		return nil, nil
+	}
+}
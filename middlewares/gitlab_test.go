@@ -0,0 +1,217 @@
+package middlewares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// signGitLabBody 按 Standard Webhooks 规范生成 "v1,<base64>" 签名
+func signGitLabBody(key []byte, id, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(id + "." + timestamp + "."))
+	h.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func TestVerifyGitLabToken(t *testing.T) {
+	cases := []struct {
+		name     string
+		received string
+		expected []string
+		err      error
+	}{
+		{"missing token", "", []string{"s1"}, ErrGitLabTokenMissing},
+		{"mismatched token", "wrong", []string{"s1"}, ErrGitLabTokenMismatch},
+		{"matching token", "s1", []string{"s1"}, nil},
+		{"matches first of multiple secrets", "old", []string{"old", "new"}, nil},
+		{"matches second of multiple secrets", "new", []string{"old", "new"}, nil},
+		{"matches none of multiple secrets", "other", []string{"old", "new"}, ErrGitLabTokenMismatch},
+		{"prefix of secret", "s", []string{"s1"}, ErrGitLabTokenMismatch},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := VerifyGitLabToken(tc.received, tc.expected...); !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifyGitLabSignature(t *testing.T) {
+	now := time.Unix(1750000000, 0)
+	body := []byte(`{"object_kind":"push"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	rawKey := []byte("raw-signing-key")
+	whsecKey := []byte("decoded-signing-key")
+	whsecToken := "whsec_" + base64.StdEncoding.EncodeToString(whsecKey)
+
+	valid := signGitLabBody(rawKey, "msg_1", ts, body)
+
+	cases := []struct {
+		name      string
+		token     string
+		id        string
+		timestamp string
+		signature string
+		err       error
+	}{
+		{"valid signature", "raw-signing-key", "msg_1", ts, valid, nil},
+		{"missing signature", "raw-signing-key", "msg_1", ts, "", ErrGitLabSignatureMissing},
+		{"wrong key", "other-key", "msg_1", ts, valid, ErrGitLabSignatureInvalid},
+		{"tampered webhook id", "raw-signing-key", "msg_2", ts, valid, ErrGitLabSignatureInvalid},
+		{"whsec base64 key", whsecToken, "msg_1", ts, signGitLabBody(whsecKey, "msg_1", ts, body), nil},
+		{"whsec token used as raw key", whsecToken, "msg_1", ts, signGitLabBody([]byte(whsecToken), "msg_1", ts, body), ErrGitLabSignatureInvalid},
+		{"missing timestamp", "raw-signing-key", "msg_1", "", valid, ErrGitLabTimestampInvalid},
+		{"malformed timestamp", "raw-signing-key", "msg_1", "yesterday", valid, ErrGitLabTimestampInvalid},
+		{
+			"expired timestamp", "raw-signing-key", "msg_1",
+			strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10),
+			signGitLabBody(rawKey, "msg_1", strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10), body),
+			ErrGitLabTimestampInvalid,
+		},
+		{
+			"future timestamp", "raw-signing-key", "msg_1",
+			strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10),
+			signGitLabBody(rawKey, "msg_1", strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10), body),
+			ErrGitLabTimestampInvalid,
+		},
+		{"multiple signatures with one valid", "raw-signing-key", "msg_1", ts, "v1,aW52YWxpZA== " + valid, nil},
+		{"multiple signatures all invalid", "raw-signing-key", "msg_1", ts, "v1,aW52YWxpZA== v1,b3RoZXI=", ErrGitLabSignatureInvalid},
+		{"unsupported signature version", "raw-signing-key", "msg_1", ts, "v2," + strings.TrimPrefix(valid, "v1,"), ErrGitLabSignatureInvalid},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Webhook-Id", tc.id)
+			header.Set("Webhook-Timestamp", tc.timestamp)
+			header.Set("Webhook-Signature", tc.signature)
+
+			if err := VerifyGitLabSignature(header, body, tc.token, now); !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifyGitLabRequest(t *testing.T) {
+	now := time.Unix(1750000000, 0)
+	body := []byte(`{"object_kind":"merge_request"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	signature := signGitLabBody([]byte("signing"), "msg_1", ts, body)
+
+	cases := []struct {
+		name         string
+		token        string
+		signature    string
+		secrets      []string
+		signingToken string
+		err          error
+	}{
+		{"nothing configured", "", "", nil, "", nil},
+		{"token only", "s1", "", []string{"s1"}, "", nil},
+		{"token missing", "", "", []string{"s1"}, "", ErrGitLabTokenMissing},
+		{"token mismatch", "s2", "", []string{"s1"}, "", ErrGitLabTokenMismatch},
+		{"rotated secrets", "s2", "", []string{"s1", "s2"}, "", nil},
+		{"signature only", "", signature, nil, "signing", nil},
+		{"signature missing", "", "", nil, "signing", ErrGitLabSignatureMissing},
+		{"token and signature", "s1", signature, []string{"s1"}, "signing", nil},
+		{"valid token but bad signature", "s1", "v1,aW52YWxpZA==", []string{"s1"}, "signing", ErrGitLabSignatureInvalid},
+		{"valid signature but bad token", "s2", signature, []string{"s1"}, "signing", ErrGitLabTokenMismatch},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Gitlab-Token", tc.token)
+			header.Set("Webhook-Id", "msg_1")
+			header.Set("Webhook-Timestamp", ts)
+			header.Set("Webhook-Signature", tc.signature)
+
+			if err := VerifyGitLabRequest(header, body, tc.secrets, tc.signingToken, now); !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestGitLabSignatureVerifyRestoresBody(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	body := `{"object_kind":"push","project_id":1,"project":{"id":1}}`
+
+	cases := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"valid token", "s1", body, http.StatusOK},
+		{"invalid token", "wrong", body, http.StatusUnauthorized},
+		{"conflicting project ids", "s1", `{"project_id":2,"project":{"id":1}}`, http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook/gitlab", strings.NewReader(tc.body))
+			req.Header.Set("X-Gitlab-Token", tc.token)
+
+			e := &core.RequestEvent{}
+			e.App = app
+			e.Request = req
+			e.Response = httptest.NewRecorder()
+
+			var received string
+			h := &hook.Hook[*core.RequestEvent]{}
+			h.BindFunc(GitLabSignatureVerify(&GitLabConfig{WebhookSecret: "s1"}))
+
+			err := h.Trigger(e, func(e *core.RequestEvent) error {
+				data, err := io.ReadAll(e.Request.Body)
+				if err != nil {
+					return err
+				}
+				received = string(data)
+				return e.NoContent(http.StatusOK)
+			})
+
+			status := http.StatusOK
+			if err != nil {
+				var apiErr *router.ApiError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("expected an api error, got %v", err)
+				}
+				status = apiErr.Status
+			}
+			if status != tc.status {
+				t.Fatalf("expected status %d, got %d (err: %v)", tc.status, status, err)
+			}
+
+			if tc.status == http.StatusOK && received != tc.body {
+				t.Fatalf("expected next handler to receive the original body %q, got %q", tc.body, received)
+			}
+			if tc.status != http.StatusOK && received != "" {
+				t.Fatalf("next handler must not run on failed verification")
+			}
+		})
+	}
+}
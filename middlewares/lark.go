@@ -2,6 +2,9 @@ package middlewares
 
 import (
 	"context"
+	"net/http"
+	"strings"
+	"time"
 
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	"github.com/pocketbase/pocketbase/core"
@@ -14,29 +17,88 @@ type LarkConfig struct {
 	BaseURL   string
 	WebURL    string
 	Client    *lark.Client
+
+	// CacheTTL 记录解析结果的缓存时长，为 0 时不缓存
+	CacheTTL time.Duration
+	// NegativeCacheTTL 未找到记录时的缓存时长，为 0 时不缓存
+	NegativeCacheTTL time.Duration
+
+	// Timeout 飞书 API 请求超时时间，为 0 时使用 SDK 默认值
+	Timeout time.Duration
+	// Transport 自定义 HTTP Transport，为 nil 时使用 SDK 默认客户端
+	Transport http.RoundTripper
+
+	// LinkSecret 签名短链接使用的密钥
+	LinkSecret string
+	// LinkTTL 签名短链接的默认有效期
+	LinkTTL time.Duration
+
+	// VerificationToken 飞书事件订阅的 Verification Token，为空时不校验
+	VerificationToken string
+	// EncryptKey 飞书事件订阅的 Encrypt Key，配置后校验请求签名并解密事件，为空时不校验签名；
+	// 与 VerificationToken 都为空时拒绝所有飞书事件
+	EncryptKey string
+}
+
+// LarkOption 飞书客户端的可选配置
+type LarkOption func(config *LarkConfig)
+
+// WithLarkTimeout 设置飞书 API 请求超时时间
+func WithLarkTimeout(timeout time.Duration) LarkOption {
+	return func(config *LarkConfig) {
+		config.Timeout = timeout
+	}
+}
+
+// WithLarkTransport 设置飞书 API 请求使用的 HTTP Transport，例如测试时指向本地桩服务
+func WithLarkTransport(transport http.RoundTripper) LarkOption {
+	return func(config *LarkConfig) {
+		config.Transport = transport
+	}
 }
 
 // NewLarkConfig 创建新的飞书配置并初始化客户端
-func NewLarkConfig(appID, appSecret, baseURL, webURL string) *LarkConfig {
+func NewLarkConfig(appID, appSecret, baseURL, webURL string, opts ...LarkOption) *LarkConfig {
 	config := &LarkConfig{
 		AppID:     appID,
 		AppSecret: appSecret,
-		BaseURL:   baseURL,
+		BaseURL:   strings.TrimRight(baseURL, "/"),
 		WebURL:    webURL,
 	}
-	config.Client = lark.NewClient(appID, appSecret)
+	for _, opt := range opts {
+		opt(config)
+	}
+	config.Client = config.newClient()
 	return config
 }
 
-// LarkAuth 创建飞书认证中间件
+// newClient 根据配置创建飞书客户端，使用配置的开放平台域名、超时和 Transport
+func (config *LarkConfig) newClient() *lark.Client {
+	var clientOpts []lark.ClientOptionFunc
+	if config.BaseURL != "" {
+		clientOpts = append(clientOpts, lark.WithOpenBaseUrl(config.BaseURL))
+	}
+	if config.Timeout > 0 {
+		clientOpts = append(clientOpts, lark.WithReqTimeout(config.Timeout))
+	}
+	if config.Transport != nil {
+		// SDK 只在自建 HttpClient 时应用 ReqTimeout，自定义客户端需要自行设置超时
+		clientOpts = append(clientOpts, lark.WithHttpClient(&http.Client{
+			Transport: config.Transport,
+			Timeout:   config.Timeout,
+		}))
+	}
+	return lark.NewClient(config.AppID, config.AppSecret, clientOpts...)
+}
+
+// LarkAuth 创建飞书中间件，将飞书配置和客户端注入请求上下文
+//
+// 该中间件本身不做请求认证，需要访问控制的路由应同时绑定 LarkAuthRequired。
 func LarkAuth(config *LarkConfig) func(e *core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
-		// 在这里可以进行飞书相关的认证或预处理
-		// 例如：验证访问令牌、检查权限等
-
 		// 确保客户端已初始化
 		if config.Client == nil {
-			config.Client = lark.NewClient(config.AppID, config.AppSecret)
+			config.Client = config.newClient()
 		}
 
 		// 将配置信息和客户端添加到请求上下文中，供后续使用
@@ -45,20 +107,22 @@ func LarkAuth(config *LarkConfig) func(e *core.RequestEvent) error {
 		e.Request = e.Request.WithContext(ctx)
 
 		// 记录请求信息
-		e.App.Logger().Info("Lark middleware processing request",
+		Logger(e.App).Info("Lark middleware processing request",
 			"baseID", e.Request.PathValue("baseID"),
 			"tableID", e.Request.PathValue("tableID"),
 			"recordID", e.Request.PathValue("recordID"),
 		)
 
-		// 可以在这里添加更多的飞书 API 认证逻辑
-		// 例如获取访问令牌等
-
 		return e.Next()
 	}
 }
 
-// LarkAuthRequired 创建需要飞书认证的中间件
+// LarkAuthRequired 创建 /base 路由的访问控制中间件
+//
+// 根据 lark_table 的 access_policy 字段决定访问策略：
+//   - public（或为空）：无需认证
+//   - signed：需要 SignLarkLink 生成的短期签名链接（exp、sig 查询参数）
+//   - auth：需要 PocketBase 认证记录（Authorization 请求头或 token 查询参数）
 func LarkAuthRequired(config *LarkConfig) func(e *core.RequestEvent) error {
 	return func(e *core.RequestEvent) error {
 		// 检查飞书配置是否完整
@@ -68,18 +132,62 @@ func LarkAuthRequired(config *LarkConfig) func(e *core.RequestEvent) error {
 
 		// 确保客户端已初始化
 		if config.Client == nil {
-			config.Client = lark.NewClient(config.AppID, config.AppSecret)
+			config.Client = config.newClient()
 		}
 
-		// 可以在这里添加飞书 token 验证逻辑
-		// 例如：从请求头中获取 token，验证其有效性等
-
 		// 将配置和客户端添加到上下文
 		ctx := context.WithValue(e.Request.Context(), "lark_config", config)
 		ctx = context.WithValue(ctx, "lark_client", config.Client)
 		e.Request = e.Request.WithContext(ctx)
 
-		return e.Next()
+		baseID := e.Request.PathValue("baseID")
+		tableID := e.Request.PathValue("tableID")
+		recordID := e.Request.PathValue("recordID")
+
+		// table 不存在时交由路由处理器返回 404
+		table, err := e.App.FindFirstRecordByData("lark_table", "table_id", tableID)
+		if err != nil {
+			return e.Next()
+		}
+
+		policy := table.GetString("access_policy")
+		switch policy {
+		case "", "public":
+			return e.Next()
+
+		case "signed":
+			if config.LinkSecret == "" {
+				Logger(e.App).Error("Lark link secret not configured for signed table", "tableID", tableID)
+				return e.ForbiddenError("Signed links are not configured", nil)
+			}
+
+			query := e.Request.URL.Query()
+			err := VerifyLarkLink(config.LinkSecret, baseID, tableID, recordID, query.Get("sig"), query.Get("exp"), time.Now())
+			if err != nil {
+				Logger(e.App).Warn("Lark link signature verification failed",
+					"tableID", tableID,
+					"recordID", recordID,
+					"reason", err.Error(),
+				)
+				return e.UnauthorizedError("Invalid or expired link", nil)
+			}
+			return e.Next()
+
+		case "auth":
+			// 只接受 Authorization 请求头，需要通过链接访问的表应使用 signed 策略
+			if e.Auth == nil {
+				Logger(e.App).Warn("Unauthenticated request to protected table",
+					"tableID", tableID,
+					"recordID", recordID,
+				)
+				return e.UnauthorizedError("The request requires valid record authorization token.", nil)
+			}
+			return e.Next()
+
+		default:
+			Logger(e.App).Error("Unknown lark_table access policy", "tableID", tableID, "policy", policy)
+			return e.ForbiddenError("Unknown access policy", nil)
+		}
 	}
 }
 
@@ -0,0 +1,290 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const larkEventsCollection = "lark_events"
+
+// larkEventStaleAfter 处理中状态超过该时长视为处理中断，允许飞书重试时重新处理
+const larkEventStaleAfter = 10 * time.Minute
+
+var (
+	ErrLarkVerifyNotConfigured = errors.New("neither lark encrypt key nor verification token is configured")
+	ErrLarkEncryptKeyMissing   = errors.New("encrypted lark event received but encrypt key is not configured")
+	ErrLarkSignatureMissing    = errors.New("missing X-Lark-Signature header")
+	ErrLarkSignatureInvalid    = errors.New("lark event signature mismatch")
+	ErrLarkTokenMismatch       = errors.New("lark event verification token mismatch")
+	ErrLarkEventDecryptFailed  = errors.New("failed to decrypt lark event")
+)
+
+// larkEventEnvelope 验证和审计需要的飞书事件字段
+type larkEventEnvelope struct {
+	Encrypt string `json:"encrypt"`
+	Schema  string `json:"schema"`
+	Type    string `json:"type"`
+	Token   string `json:"token"`
+	Header  struct {
+		EventID    string `json:"event_id"`
+		EventType  string `json:"event_type"`
+		CreateTime string `json:"create_time"`
+		Token      string `json:"token"`
+		AppID      string `json:"app_id"`
+		TenantKey  string `json:"tenant_key"`
+	} `json:"header"`
+}
+
+// LarkEventVerify 创建飞书事件回调验证中间件
+//
+// 配置了 EncryptKey 时，非URL验证请求需要通过 X-Lark-Signature 签名校验
+// （sha256(timestamp + nonce + encryptKey + body)），加密的请求体会被解密；
+// 配置了 VerificationToken 时校验事件中的 token。
+// 两者都未配置时无法认证请求来源，所有请求都被拒绝。
+// 验证失败返回 401，请求体被替换为解密后的明文供后续处理器读取。
+func LarkEventVerify(config *LarkConfig) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if config.EncryptKey == "" && config.VerificationToken == "" {
+			Logger(e.App).Error("Lark event rejected", "reason", ErrLarkVerifyNotConfigured.Error())
+			return e.UnauthorizedError("Lark event verification is not configured", nil)
+		}
+
+		body, err := io.ReadAll(e.Request.Body)
+		if err != nil {
+			Logger(e.App).Error("Failed to read request body", "error", err)
+			return e.BadRequestError("Failed to read request body", err)
+		}
+
+		plain, err := DecryptLarkEvent(body, config.EncryptKey)
+		if err != nil {
+			Logger(e.App).Warn("Lark event decryption failed", "reason", err.Error())
+			return e.BadRequestError("Invalid encrypted Lark event", nil)
+		}
+
+		var envelope larkEventEnvelope
+		if err := json.Unmarshal(plain, &envelope); err != nil {
+			Logger(e.App).Warn("Failed to parse lark event", "error", err)
+			return e.BadRequestError("Invalid Lark event format", err)
+		}
+
+		// URL验证请求不携带签名
+		if envelope.Type != "url_verification" {
+			if err := VerifyLarkSignature(e.Request.Header, body, config.EncryptKey); err != nil {
+				Logger(e.App).Warn("Lark event signature verification failed",
+					"reason", err.Error(),
+					"eventType", envelope.Header.EventType,
+					"eventID", envelope.Header.EventID,
+				)
+				return e.UnauthorizedError("Invalid Lark event signature", nil)
+			}
+		}
+
+		// URL验证请求的 token 在顶层，v2 事件的在 header 中
+		token := envelope.Header.Token
+		if envelope.Type == "url_verification" || envelope.Schema == "" {
+			token = envelope.Token
+		}
+		if err := VerifyLarkToken(token, config.VerificationToken); err != nil {
+			Logger(e.App).Warn("Lark event verification failed",
+				"reason", err.Error(),
+				"eventType", envelope.Header.EventType,
+				"eventID", envelope.Header.EventID,
+			)
+			return e.UnauthorizedError("Invalid verification token", nil)
+		}
+
+		e.Request.Body = io.NopCloser(bytes.NewReader(plain))
+
+		return e.Next()
+	}
+}
+
+// DecryptLarkEvent 解密 {"encrypt": "..."} 形式的请求体（AES-256-CBC，密钥为 sha256(encryptKey)），未加密的请求体原样返回
+func DecryptLarkEvent(body []byte, encryptKey string) ([]byte, error) {
+	var envelope larkEventEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Encrypt == "" {
+		return body, nil
+	}
+
+	if encryptKey == "" {
+		return nil, ErrLarkEncryptKeyMissing
+	}
+
+	plain, err := larkevent.EventDecrypt(envelope.Encrypt, encryptKey)
+	if err != nil {
+		return nil, errors.Join(ErrLarkEventDecryptFailed, err)
+	}
+	return plain, nil
+}
+
+// VerifyLarkSignature 校验飞书事件签名，encryptKey 为空时不校验
+func VerifyLarkSignature(header http.Header, body []byte, encryptKey string) error {
+	if encryptKey == "" {
+		return nil
+	}
+
+	signature := header.Get(larkevent.EventSignature)
+	if signature == "" {
+		return ErrLarkSignatureMissing
+	}
+
+	expected := larkevent.Signature(
+		header.Get(larkevent.EventRequestTimestamp),
+		header.Get(larkevent.EventRequestNonce),
+		encryptKey,
+		string(body),
+	)
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		return ErrLarkSignatureInvalid
+	}
+	return nil
+}
+
+// VerifyLarkToken 使用常量时间比较校验 Verification Token，expected 为空时不校验
+func VerifyLarkToken(received, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(received), []byte(expected)) != 1 {
+		return ErrLarkTokenMismatch
+	}
+	return nil
+}
+
+// LarkEventAudit 创建飞书事件审计中间件
+//
+// 以 event_id 为键将每个 v2 事件记录到 lark_events，已成功处理或正在处理的重复推送
+// 直接返回成功而不再交给处理器；处理失败的事件允许飞书重试时重新处理。需绑定在 LarkEventVerify 之后。
+func LarkEventAudit() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		body, err := io.ReadAll(e.Request.Body)
+		if err != nil {
+			Logger(e.App).Error("Failed to read request body", "error", err)
+			return e.BadRequestError("Failed to read request body", err)
+		}
+		e.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var envelope larkEventEnvelope
+		if err := json.Unmarshal(body, &envelope); err != nil || envelope.Header.EventID == "" {
+			return e.Next()
+		}
+		eventID := envelope.Header.EventID
+
+		collection, err := e.App.FindCachedCollectionByNameOrId(larkEventsCollection)
+		if err != nil {
+			Logger(e.App).Warn("lark_events collection not found", "error", err)
+			return e.Next()
+		}
+
+		now := types.NowDateTime()
+		event, err := e.App.FindFirstRecordByData(collection, "event_id", eventID)
+		if err == nil {
+			status := event.GetString("status")
+			stale := event.GetDateTime("received_at").Time().Add(larkEventStaleAfter).Before(now.Time())
+			if status == "processed" || status == "processing" && !stale {
+				Logger(e.App).Info("Duplicate Lark event ignored",
+					"eventID", eventID,
+					"eventType", event.GetString("event_type"),
+					"status", status,
+				)
+				return e.JSON(http.StatusOK, map[string]interface{}{
+					"status":   "success",
+					"message":  "Duplicate event ignored",
+					"event_id": eventID,
+				})
+			}
+		} else {
+			event = core.NewRecord(collection)
+			event.Set("event_id", eventID)
+		}
+
+		event.Set("event_type", envelope.Header.EventType)
+		event.Set("schema", envelope.Schema)
+		event.Set("app_id", envelope.Header.AppID)
+		event.Set("tenant_key", envelope.Header.TenantKey)
+		if createTime, err := strconv.ParseInt(envelope.Header.CreateTime, 10, 64); err == nil {
+			event.Set("create_time", time.UnixMilli(createTime))
+		}
+		event.Set("received_at", now)
+		event.Set("status", "processing")
+		event.Set("error", "")
+		event.Set("attempts", event.GetInt("attempts")+1)
+		event.Set("event_data", string(body))
+
+		if err := e.App.Save(event); err != nil {
+			// 唯一索引冲突说明并发的重复推送已被记录
+			if event.IsNew() {
+				Logger(e.App).Info("Concurrent Lark event ignored", "eventID", eventID, "error", err)
+				return e.JSON(http.StatusOK, map[string]interface{}{
+					"status":   "success",
+					"message":  "Duplicate event ignored",
+					"event_id": eventID,
+				})
+			}
+			Logger(e.App).Error("Failed to save Lark event", "eventID", eventID, "error", err)
+		}
+
+		handlerErr := e.Next()
+
+		event.Set("processed_at", types.NowDateTime())
+		event.Set("response_status", e.Status())
+		if handlerErr != nil {
+			event.Set("status", "failed")
+			event.Set("error", handlerErr.Error())
+		} else {
+			event.Set("status", "processed")
+		}
+
+		if err := e.App.Save(event); err != nil {
+			Logger(e.App).Error("Failed to update Lark event", "eventID", eventID, "error", err)
+		}
+
+		return handlerErr
+	}
+}
+
+// BindLarkEventCleanup 注册定时任务，清理超过保留时长的飞书事件记录
+func BindLarkEventCleanup(app core.App, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	app.Cron().MustAdd("lark_events_cleanup", "45 * * * *", func() {
+		cutoff := types.NowDateTime().Add(-retention)
+
+		records, err := app.FindRecordsByFilter(
+			larkEventsCollection,
+			"received_at < {:cutoff}",
+			"",
+			0,
+			0,
+			dbx.Params{"cutoff": cutoff.String()},
+		)
+		if err != nil {
+			Logger(app).Error("Failed to load expired Lark events", "error", err)
+			return
+		}
+
+		for _, record := range records {
+			if err := app.Delete(record); err != nil {
+				Logger(app).Error("Failed to delete expired Lark event", "error", err, "recordID", record.Id)
+				return
+			}
+		}
+
+		if len(records) > 0 {
+			Logger(app).Info("Expired Lark events cleaned up", "deleted", len(records), "retention", retention.String())
+		}
+	})
+}
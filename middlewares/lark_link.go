@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrLinkSignatureMissing = errors.New("link signature missing")
+	ErrLinkSignatureInvalid = errors.New("link signature invalid")
+	ErrLinkExpired          = errors.New("link expired")
+)
+
+// SignLarkLink 为 /base 短链接生成签名，返回签名和过期时间戳（Unix 秒）
+func SignLarkLink(secret, baseID, tableID, recordID string, expiresAt time.Time) (string, int64) {
+	exp := expiresAt.Unix()
+	return larkLinkSignature(secret, baseID, tableID, recordID, exp), exp
+}
+
+// VerifyLarkLink 校验 /base 短链接的签名和有效期
+func VerifyLarkLink(secret, baseID, tableID, recordID, signature, expires string, now time.Time) error {
+	if signature == "" || expires == "" {
+		return ErrLinkSignatureMissing
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrLinkSignatureInvalid
+	}
+
+	expected := larkLinkSignature(secret, baseID, tableID, recordID, exp)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrLinkSignatureInvalid
+	}
+
+	if now.Unix() > exp {
+		return ErrLinkExpired
+	}
+
+	return nil
+}
+
+// larkLinkSignature 计算 base/table/record 及过期时间的 HMAC-SHA256 签名
+func larkLinkSignature(secret, baseID, tableID, recordID string, exp int64) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(baseID + "/" + tableID + "/" + recordID + "\n" + strconv.FormatInt(exp, 10)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,160 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// redactedValue 替换敏感信息的占位符
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeyParts 日志属性名包含这些片段时视为敏感字段
+var sensitiveKeyParts = []string{"token", "secret", "password", "signature", "authorization", "encrypt_key", "encryptkey"}
+
+// secretRegistry 已注册的需要在日志中掩码的密钥
+var secretRegistry = struct {
+	sync.RWMutex
+	values map[string]struct{}
+}{values: map[string]struct{}{}}
+
+// RegisterSecrets 注册需要在日志中掩码的密钥，例如 webhook 密钥、飞书 App Secret
+func RegisterSecrets(secrets ...string) {
+	secretRegistry.Lock()
+	defer secretRegistry.Unlock()
+
+	for _, secret := range secrets {
+		// 过短的值容易误伤普通日志内容
+		if len(secret) < 6 {
+			continue
+		}
+		secretRegistry.values[secret] = struct{}{}
+	}
+}
+
+// Fingerprint 返回值的短指纹，用于在不暴露原值的情况下区分不同的密钥
+func Fingerprint(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// Logger 返回会掩码敏感信息的 app 日志记录器
+//
+// 敏感属性（名称包含 token、secret 等）的值会被替换为 [REDACTED]，并附加
+// "<属性名>Fingerprint" 指纹属性；其他属性和日志消息中出现的已注册密钥也会被掩码。
+func Logger(app core.App) *slog.Logger {
+	return slog.New(&redactingHandler{inner: app.Logger().Handler()})
+}
+
+// redactingHandler 包装 slog.Handler，在输出前掩码敏感信息
+type redactingHandler struct {
+	inner slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, redactString(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr)...)
+		return true
+	})
+	return h.inner.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var redacted []slog.Attr
+	for _, attr := range attrs {
+		redacted = append(redacted, redactAttr(attr)...)
+	}
+	return &redactingHandler{inner: h.inner.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{inner: h.inner.WithGroup(name)}
+}
+
+// redactAttr 掩码单个日志属性，敏感属性会额外返回指纹属性
+func redactAttr(attr slog.Attr) []slog.Attr {
+	value := attr.Value.Resolve()
+
+	if value.Kind() == slog.KindGroup {
+		var group []any
+		for _, child := range value.Group() {
+			for _, redacted := range redactAttr(child) {
+				group = append(group, redacted)
+			}
+		}
+		return []slog.Attr{slog.Group(attr.Key, group...)}
+	}
+
+	if isSensitiveKey(attr.Key) {
+		switch v := value.Any().(type) {
+		case string:
+			if v == "" {
+				return []slog.Attr{attr}
+			}
+			return []slog.Attr{
+				slog.String(attr.Key, redactedValue),
+				slog.String(attr.Key+"Fingerprint", Fingerprint(v)),
+			}
+		case []string:
+			fingerprints := make([]string, 0, len(v))
+			for _, item := range v {
+				fingerprints = append(fingerprints, Fingerprint(item))
+			}
+			return []slog.Attr{
+				slog.String(attr.Key, redactedValue),
+				slog.Any(attr.Key+"Fingerprint", fingerprints),
+			}
+		default:
+			return []slog.Attr{slog.String(attr.Key, redactedValue)}
+		}
+	}
+
+	switch v := value.Any().(type) {
+	case string:
+		return []slog.Attr{slog.String(attr.Key, redactString(v))}
+	case error:
+		return []slog.Attr{slog.String(attr.Key, redactString(v.Error()))}
+	default:
+		return []slog.Attr{{Key: attr.Key, Value: value}}
+	}
+}
+
+// isSensitiveKey 判断属性名是否表示敏感字段
+func isSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	if strings.HasSuffix(lower, "fingerprint") || strings.HasSuffix(lower, "configured") {
+		return false
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString 将字符串中出现的已注册密钥替换为占位符
+func redactString(s string) string {
+	secretRegistry.RLock()
+	defer secretRegistry.RUnlock()
+
+	for secret := range secretRegistry.values {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, redactedValue)
+		}
+	}
+	return s
+}
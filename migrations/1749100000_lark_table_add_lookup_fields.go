@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 lark_table collection
+		collection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		// 记录查找使用的字段名，为空时使用默认的"编号"
+		collection.Fields.Add(&core.TextField{
+			Name:     "lookup_field",
+			Required: false,
+		})
+
+		// 记录查找使用的操作符，为空时使用 is
+		collection.Fields.Add(&core.SelectField{
+			Name:      "lookup_operator",
+			Required:  false,
+			MaxSelect: 1,
+			Values:    []string{"is", "contains"},
+		})
+
+		// 查找值的类型转换，为空时按原样作为文本查找
+		collection.Fields.Add(&core.SelectField{
+			Name:      "lookup_value_type",
+			Required:  false,
+			MaxSelect: 1,
+			Values:    []string{"text", "number", "upper", "lower"},
+		})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚：删除查找相关字段
+		collection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"lookup_field", "lookup_operator", "lookup_value_type"} {
+			field := collection.Fields.GetByName(name)
+			if field != nil {
+				collection.Fields.RemoveById(field.GetId())
+			}
+		}
+
+		return app.Save(collection)
+	})
+}
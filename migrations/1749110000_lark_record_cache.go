@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建 lark_record_cache 集合，缓存记录编号到飞书记录及分享链接的解析结果
+		collection := core.NewBaseCollection("lark_record_cache")
+
+		// 配置集合基本信息
+		collection.Name = "lark_record_cache"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.TextField{
+			Name:     "base_id",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "table_id",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "record_key",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "lark_record_id",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "shared_url",
+			Required: false,
+		})
+
+		// found 为 false 表示未命中的负缓存
+		collection.Fields.Add(&core.BoolField{
+			Name:     "found",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "expires_at",
+			Required: true,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_lark_record_cache_key ON lark_record_cache (base_id, table_id, record_key)",
+			"CREATE INDEX idx_lark_record_cache_table_id ON lark_record_cache (table_id)",
+			"CREATE INDEX idx_lark_record_cache_expires_at ON lark_record_cache (expires_at)",
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚操作：删除 lark_record_cache 集合
+		collection, err := app.FindCollectionByNameOrId("lark_record_cache")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
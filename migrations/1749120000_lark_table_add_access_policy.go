@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 lark_table collection
+		collection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		// 添加 access_policy 字段：
+		// public - 无需认证（为空时的默认值）
+		// signed - 需要带签名的短期链接
+		// auth   - 需要 PocketBase 认证记录
+		collection.Fields.Add(&core.SelectField{
+			Name:      "access_policy",
+			Required:  false,
+			MaxSelect: 1,
+			Values:    []string{"public", "signed", "auth"},
+		})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚：删除 access_policy 字段
+		collection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		field := collection.Fields.GetByName("access_policy")
+		if field != nil {
+			collection.Fields.RemoveById(field.GetId())
+		}
+
+		return app.Save(collection)
+	})
+}
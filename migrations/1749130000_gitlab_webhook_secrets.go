@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建 gitlab_webhook_secrets 集合
+		collection := core.NewBaseCollection("gitlab_webhook_secrets")
+
+		// 配置集合基本信息
+		collection.Name = "gitlab_webhook_secrets"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 密钥作用范围：项目、组或整个 GitLab 实例
+		collection.Fields.Add(&core.SelectField{
+			Name:      "scope",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"project", "group", "instance"},
+		})
+
+		// 项目 ID 或路径、组 ID 或路径、实例 URL（为空时匹配所有实例）
+		collection.Fields.Add(&core.TextField{
+			Name:     "scope_id",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "secret",
+			Required: true,
+			Hidden:   true,
+		})
+
+		// 同一范围可以同时存在多个有效密钥，便于轮换
+		collection.Fields.Add(&core.BoolField{
+			Name:     "active",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "expires_at",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "description",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE INDEX idx_gitlab_webhook_secrets_scope ON gitlab_webhook_secrets (scope, scope_id)",
+			"CREATE INDEX idx_gitlab_webhook_secrets_active ON gitlab_webhook_secrets (active)",
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚操作：删除 gitlab_webhook_secrets 集合
+		collection, err := app.FindCollectionByNameOrId("gitlab_webhook_secrets")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
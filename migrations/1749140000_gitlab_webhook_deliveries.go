@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建 gitlab_webhook_deliveries 集合，按 X-Gitlab-Event-UUID 记录投递结果用于去重
+		collection := core.NewBaseCollection("gitlab_webhook_deliveries")
+
+		// 配置集合基本信息
+		collection.Name = "gitlab_webhook_deliveries"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.TextField{
+			Name:     "event_uuid",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "hook_type",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "received_at",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.SelectField{
+			Name:      "status",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"processing", "processed", "failed"},
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "response_status",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "error",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "attempts",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "processed_at",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_gitlab_delivery_event_uuid ON gitlab_webhook_deliveries (event_uuid)",
+			"CREATE INDEX idx_gitlab_delivery_received_at ON gitlab_webhook_deliveries (received_at)",
+			"CREATE INDEX idx_gitlab_delivery_status ON gitlab_webhook_deliveries (status)",
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚操作：删除 gitlab_webhook_deliveries 集合
+		collection, err := app.FindCollectionByNameOrId("gitlab_webhook_deliveries")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,160 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		// 创建 gitlab_merge_request_events 集合，保存每次 MR 事件的原始数据
+		collection := core.NewBaseCollection("gitlab_merge_request_events")
+
+		// 配置集合基本信息
+		collection.Name = "gitlab_merge_request_events"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.RelationField{
+			Name:          "merge_request",
+			Required:      true,
+			CollectionId:  mrCollection.Id,
+			MaxSelect:     1,
+			CascadeDelete: true,
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "mr_id",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "mr_iid",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "project_id",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "action",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "state",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "event_source",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "received_at",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.JSONField{
+			Name:     "event_data",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE INDEX idx_gitlab_mr_events_merge_request ON gitlab_merge_request_events (merge_request)",
+			"CREATE INDEX idx_gitlab_mr_events_project_iid ON gitlab_merge_request_events (project_id, mr_iid)",
+			"CREATE INDEX idx_gitlab_mr_events_action ON gitlab_merge_request_events (action)",
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 回填：将已有的事件行迁移到历史集合，每个 (project_id, mr_iid) 只保留最后一行作为当前状态
+		if err := backfillMergeRequestEvents(app, mrCollection, collection); err != nil {
+			return err
+		}
+
+		// 为当前状态表添加唯一索引
+		mrCollection.AddIndex("idx_gitlab_mr_project_iid", true, "project_id, mr_iid", "")
+
+		return app.Save(mrCollection)
+	}, func(app core.App) error {
+		// 回滚操作：删除唯一索引和 gitlab_merge_request_events 集合
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		mrCollection.RemoveIndex("idx_gitlab_mr_project_iid")
+		if err := app.Save(mrCollection); err != nil {
+			return err
+		}
+
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_request_events")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
+
+// backfillMergeRequestEvents 将 gitlab_merge_requests 中的事件行拆分为当前状态和事件历史
+func backfillMergeRequestEvents(app core.App, mrCollection, eventsCollection *core.Collection) error {
+	var records []*core.Record
+	if err := app.RecordQuery(mrCollection).OrderBy("rowid ASC").All(&records); err != nil {
+		return err
+	}
+
+	// 按插入顺序分组，最后一行为最新状态
+	groups := map[string][]*core.Record{}
+	var keys []string
+	for _, record := range records {
+		key := fmt.Sprintf("%d:%d", record.GetInt("project_id"), record.GetInt("mr_iid"))
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], record)
+	}
+
+	for _, key := range keys {
+		rows := groups[key]
+		canonical := rows[len(rows)-1]
+
+		for _, row := range rows {
+			event := core.NewRecord(eventsCollection)
+			event.Set("merge_request", canonical.Id)
+			event.Set("mr_id", row.GetInt("mr_id"))
+			event.Set("mr_iid", row.GetInt("mr_iid"))
+			event.Set("project_id", row.GetInt("project_id"))
+			event.Set("action", row.GetString("action"))
+			event.Set("state", row.GetString("state"))
+			event.Set("event_data", row.Get("event_data"))
+
+			if err := app.Save(event); err != nil {
+				return err
+			}
+		}
+
+		for _, row := range rows[:len(rows)-1] {
+			if err := app.Delete(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
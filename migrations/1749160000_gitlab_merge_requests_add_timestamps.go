@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// gitlabTimeFormats GitLab webhook 中出现的时间格式，迁移回填时使用，不依赖 router 包以免处理器代码变更影响历史迁移
+var gitlabTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 UTC",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02",
+}
+
+// parseGitLabTime 解析 GitLab webhook 中的时间，无法解析时返回零值
+func parseGitLabTime(value string) time.Time {
+	for _, format := range gitlabTimeFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 gitlab_merge_requests collection
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		// 添加 GitLab 侧的创建和更新时间
+		collection.Fields.Add(&core.DateField{
+			Name:     "created_at",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "updated_at",
+			Required: false,
+		})
+
+		// 添加事件来源，区分项目 webhook 和 System Hook
+		collection.Fields.Add(&core.SelectField{
+			Name:      "event_source",
+			Required:  false,
+			MaxSelect: 1,
+			Values:    []string{"project_hook", "system_hook"},
+		})
+
+		collection.AddIndex("idx_gitlab_mr_updated_at", false, "updated_at", "")
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 回填：从 event_data 中解析 GitLab 时间，事件来源取最近一条事件历史的来源，没有历史时默认为项目 webhook
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			source := "project_hook"
+			if events, err := app.FindRecordsByFilter(
+				"gitlab_merge_request_events",
+				"merge_request = {:id} && event_source != ''",
+				"-received_at",
+				1,
+				0,
+				dbx.Params{"id": record.Id},
+			); err == nil && len(events) > 0 {
+				source = events[0].GetString("event_source")
+			}
+			record.Set("event_source", source)
+
+			var data struct {
+				ObjectAttributes struct {
+					CreatedAt string `json:"created_at"`
+					UpdatedAt string `json:"updated_at"`
+				} `json:"object_attributes"`
+			}
+			if err := record.UnmarshalJSONField("event_data", &data); err != nil {
+				app.Logger().Warn("Failed to parse merge request event data, skipping time backfill",
+					"recordID", record.Id,
+					"error", err,
+				)
+			}
+
+			if createdAt := parseGitLabTime(data.ObjectAttributes.CreatedAt); !createdAt.IsZero() {
+				record.Set("created_at", createdAt)
+			}
+			if updatedAt := parseGitLabTime(data.ObjectAttributes.UpdatedAt); !updatedAt.IsZero() {
+				record.Set("updated_at", updatedAt)
+			}
+
+			// 只回填新增字段，不因历史记录的其他字段校验失败而中断迁移
+			if err := app.SaveNoValidate(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		// 回滚：删除新增字段
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_gitlab_mr_updated_at")
+		for _, name := range []string{"created_at", "updated_at", "event_source"} {
+			field := collection.Fields.GetByName(name)
+			if field != nil {
+				collection.Fields.RemoveById(field.GetId())
+			}
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,224 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建推送事件表
+		pushCollection, err := createPushEventsCollection(app)
+		if err != nil {
+			return err
+		}
+
+		// 创建提交表
+		return createCommitsCollection(app, pushCollection)
+	}, func(app core.App) error {
+		// 回滚操作：删除相关集合（先删除引用推送事件的提交表）
+		collections := []string{
+			"gitlab_commits",
+			"gitlab_push_events",
+		}
+
+		for _, name := range collections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err == nil {
+				if err := app.Delete(collection); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// createPushEventsCollection 创建推送事件集合
+func createPushEventsCollection(app core.App) (*core.Collection, error) {
+	collection := core.NewBaseCollection("gitlab_push_events")
+	collection.Name = "gitlab_push_events"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "ref",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "branch",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "before",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "after",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "checkout_sha",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "total_commits_count",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_email",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "pushed_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE INDEX idx_push_project_id ON gitlab_push_events (project_id)",
+		"CREATE INDEX idx_push_project_branch ON gitlab_push_events (project_id, branch)",
+		"CREATE INDEX idx_push_pushed_at ON gitlab_push_events (pushed_at)",
+	}
+
+	if err := app.Save(collection); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// createCommitsCollection 创建提交集合，按 (project_id, sha) 去重
+func createCommitsCollection(app core.App, pushCollection *core.Collection) error {
+	collection := core.NewBaseCollection("gitlab_commits")
+	collection.Name = "gitlab_commits"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.TextField{
+		Name:     "sha",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	// 首次推送该提交的推送事件
+	collection.Fields.Add(&core.RelationField{
+		Name:         "push_event",
+		Required:     false,
+		CollectionId: pushCollection.Id,
+		MaxSelect:    1,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "ref",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "branch",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "title",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "message",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "author_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "author_email",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "committed_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "pushed_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "added",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "modified",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "removed",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE UNIQUE INDEX idx_commit_project_sha ON gitlab_commits (project_id, sha)",
+		"CREATE INDEX idx_commit_sha ON gitlab_commits (sha)",
+		"CREATE INDEX idx_commit_project_branch_pushed ON gitlab_commits (project_id, branch, pushed_at)",
+	}
+
+	return app.Save(collection)
+}
@@ -0,0 +1,202 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建标签表
+		if err := createTagsCollection(app); err != nil {
+			return err
+		}
+
+		// 为MR添加合并提交SHA，用于计算两个标签之间合入的MR
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "merge_commit_sha",
+			Required: false,
+		})
+
+		collection.AddIndex("idx_gitlab_mr_project_merge_commit", false, "project_id, merge_commit_sha", "")
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 回填：从 event_data 中解析合并提交SHA
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			var data struct {
+				ObjectAttributes struct {
+					MergeCommitSHA string `json:"merge_commit_sha"`
+				} `json:"object_attributes"`
+			}
+			if err := record.UnmarshalJSONField("event_data", &data); err != nil {
+				app.Logger().Warn("Failed to parse merge request event data, skipping backfill",
+					"recordID", record.Id,
+					"error", err,
+				)
+				continue
+			}
+
+			if data.ObjectAttributes.MergeCommitSHA == "" {
+				continue
+			}
+
+			record.Set("merge_commit_sha", data.ObjectAttributes.MergeCommitSHA)
+			if err := app.Save(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		// 回滚：删除合并提交SHA字段和标签表
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_gitlab_mr_project_merge_commit")
+		if field := collection.Fields.GetByName("merge_commit_sha"); field != nil {
+			collection.Fields.RemoveById(field.GetId())
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		tags, err := app.FindCollectionByNameOrId("gitlab_tags")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(tags)
+	})
+}
+
+// createTagsCollection 创建标签集合，按 (project_id, tag_name) 唯一
+func createTagsCollection(app core.App) error {
+	collection := core.NewBaseCollection("gitlab_tags")
+	collection.Name = "gitlab_tags"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "tag_name",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "ref",
+		Required: false,
+	})
+
+	// 标签指向的提交SHA
+	collection.Fields.Add(&core.TextField{
+		Name:     "target_sha",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "message",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_email",
+		Required: false,
+	})
+
+	// 语义化版本解析结果
+	collection.Fields.Add(&core.BoolField{
+		Name:     "is_semver",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "semver_major",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "semver_minor",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "semver_patch",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "semver_prerelease",
+		Required: false,
+	})
+
+	// 软删除
+	collection.Fields.Add(&core.BoolField{
+		Name:     "deleted",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "deleted_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "pushed_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE UNIQUE INDEX idx_tag_project_name ON gitlab_tags (project_id, tag_name)",
+		"CREATE INDEX idx_tag_project_semver ON gitlab_tags (project_id, is_semver, deleted)",
+		"CREATE INDEX idx_tag_target_sha ON gitlab_tags (target_sha)",
+	}
+
+	return app.Save(collection)
+}
@@ -0,0 +1,268 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建Issue当前状态表
+		issuesCollection, err := createIssuesCollection(app)
+		if err != nil {
+			return err
+		}
+
+		// 创建Issue事件历史表
+		if err := createIssueEventsCollection(app, issuesCollection); err != nil {
+			return err
+		}
+
+		// 为评论事件添加Issue关联
+		notesCollection, err := app.FindCollectionByNameOrId("gitlab_note_events")
+		if err != nil {
+			return err
+		}
+
+		notesCollection.Fields.Add(&core.RelationField{
+			Name:         "issue",
+			Required:     false,
+			CollectionId: issuesCollection.Id,
+			MaxSelect:    1,
+		})
+
+		notesCollection.AddIndex("idx_gitlab_note_issue", false, "issue", "")
+
+		return app.Save(notesCollection)
+	}, func(app core.App) error {
+		// 回滚：删除评论事件的Issue关联，再删除Issue相关集合
+		notesCollection, err := app.FindCollectionByNameOrId("gitlab_note_events")
+		if err != nil {
+			return err
+		}
+
+		notesCollection.RemoveIndex("idx_gitlab_note_issue")
+		if field := notesCollection.Fields.GetByName("issue"); field != nil {
+			notesCollection.Fields.RemoveById(field.GetId())
+		}
+
+		if err := app.Save(notesCollection); err != nil {
+			return err
+		}
+
+		collections := []string{
+			"gitlab_issue_events",
+			"gitlab_issues",
+		}
+
+		for _, name := range collections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err == nil {
+				if err := app.Delete(collection); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// createIssuesCollection 创建Issue当前状态集合，按 (project_id, iid) 唯一
+func createIssuesCollection(app core.App) (*core.Collection, error) {
+	collection := core.NewBaseCollection("gitlab_issues")
+	collection.Name = "gitlab_issues"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "issue_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "iid",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "title",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "description",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "state",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "action",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "author_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "assignee_ids",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "assignees",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "labels",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "milestone_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "due_date",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "weight",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.BoolField{
+		Name:     "confidential",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "created_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "updated_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "closed_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE UNIQUE INDEX idx_gitlab_issue_project_iid ON gitlab_issues (project_id, iid)",
+		"CREATE INDEX idx_gitlab_issue_state ON gitlab_issues (state)",
+		"CREATE INDEX idx_gitlab_issue_milestone ON gitlab_issues (milestone_id)",
+		"CREATE INDEX idx_gitlab_issue_due_date ON gitlab_issues (due_date)",
+	}
+
+	if err := app.Save(collection); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// createIssueEventsCollection 创建Issue事件历史集合
+func createIssueEventsCollection(app core.App, issuesCollection *core.Collection) error {
+	collection := core.NewBaseCollection("gitlab_issue_events")
+	collection.Name = "gitlab_issue_events"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.RelationField{
+		Name:          "issue",
+		Required:      true,
+		CollectionId:  issuesCollection.Id,
+		MaxSelect:     1,
+		CascadeDelete: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "iid",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "action",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "state",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "changes",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "received_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE INDEX idx_gitlab_issue_events_issue ON gitlab_issue_events (issue)",
+		"CREATE INDEX idx_gitlab_issue_events_project_iid ON gitlab_issue_events (project_id, iid)",
+		"CREATE INDEX idx_gitlab_issue_events_action ON gitlab_issue_events (action)",
+	}
+
+	return app.Save(collection)
+}
@@ -0,0 +1,347 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		// 创建流水线表
+		pipelinesCollection, err := createPipelinesCollection(app, mrCollection)
+		if err != nil {
+			return err
+		}
+
+		// 创建作业表
+		if err := createJobsCollection(app, pipelinesCollection); err != nil {
+			return err
+		}
+
+		// 为MR添加最新流水线状态
+		mrCollection.Fields.Add(&core.RelationField{
+			Name:         "head_pipeline",
+			Required:     false,
+			CollectionId: pipelinesCollection.Id,
+			MaxSelect:    1,
+		})
+
+		mrCollection.Fields.Add(&core.NumberField{
+			Name:     "head_pipeline_id",
+			Required: false,
+		})
+
+		mrCollection.Fields.Add(&core.TextField{
+			Name:     "pipeline_status",
+			Required: false,
+		})
+
+		return app.Save(mrCollection)
+	}, func(app core.App) error {
+		// 回滚：删除MR的流水线字段，再删除作业表和流水线表
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"head_pipeline", "head_pipeline_id", "pipeline_status"} {
+			if field := mrCollection.Fields.GetByName(name); field != nil {
+				mrCollection.Fields.RemoveById(field.GetId())
+			}
+		}
+
+		if err := app.Save(mrCollection); err != nil {
+			return err
+		}
+
+		collections := []string{
+			"gitlab_jobs",
+			"gitlab_pipelines",
+		}
+
+		for _, name := range collections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err == nil {
+				if err := app.Delete(collection); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// createPipelinesCollection 创建流水线集合，按 pipeline_id 唯一
+func createPipelinesCollection(app core.App, mrCollection *core.Collection) (*core.Collection, error) {
+	collection := core.NewBaseCollection("gitlab_pipelines")
+	collection.Name = "gitlab_pipelines"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "pipeline_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "pipeline_iid",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "ref",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.BoolField{
+		Name:     "tag",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "sha",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "before_sha",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "source",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "status",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "detailed_status",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "stages",
+		Required: false,
+	})
+
+	// 状态变化记录，每项为 {status, at}
+	collection.Fields.Add(&core.JSONField{
+		Name:     "status_history",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "duration",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "queued_duration",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.RelationField{
+		Name:         "merge_request",
+		Required:     false,
+		CollectionId: mrCollection.Id,
+		MaxSelect:    1,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "mr_iid",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "created_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "finished_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE UNIQUE INDEX idx_gitlab_pipeline_id ON gitlab_pipelines (pipeline_id)",
+		"CREATE INDEX idx_gitlab_pipeline_project_ref ON gitlab_pipelines (project_id, ref)",
+		"CREATE INDEX idx_gitlab_pipeline_merge_request ON gitlab_pipelines (merge_request)",
+		"CREATE INDEX idx_gitlab_pipeline_status ON gitlab_pipelines (status)",
+	}
+
+	if err := app.Save(collection); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// createJobsCollection 创建作业集合，按 job_id 唯一
+func createJobsCollection(app core.App, pipelinesCollection *core.Collection) error {
+	collection := core.NewBaseCollection("gitlab_jobs")
+	collection.Name = "gitlab_jobs"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "job_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.RelationField{
+		Name:          "pipeline",
+		Required:      false,
+		CollectionId:  pipelinesCollection.Id,
+		MaxSelect:     1,
+		CascadeDelete: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "pipeline_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "stage",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "status",
+		Required: false,
+	})
+
+	// 状态变化记录，每项为 {status, at}
+	collection.Fields.Add(&core.JSONField{
+		Name:     "status_history",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "duration",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "queued_duration",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "failure_reason",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.BoolField{
+		Name:     "allow_failure",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "runner_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "runner_description",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "ref",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "sha",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "created_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "started_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "finished_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE UNIQUE INDEX idx_gitlab_job_id ON gitlab_jobs (job_id)",
+		"CREATE INDEX idx_gitlab_job_pipeline ON gitlab_jobs (pipeline)",
+		"CREATE INDEX idx_gitlab_job_pipeline_id ON gitlab_jobs (pipeline_id)",
+		"CREATE INDEX idx_gitlab_job_project_status ON gitlab_jobs (project_id, status)",
+	}
+
+	return app.Save(collection)
+}
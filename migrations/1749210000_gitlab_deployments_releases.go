@@ -0,0 +1,326 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建部署表
+		if err := createDeploymentsCollection(app); err != nil {
+			return err
+		}
+
+		// 创建发布表
+		if err := createReleasesCollection(app); err != nil {
+			return err
+		}
+
+		// 为MR添加合并时间，用于计算变更前置时间
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "merged_at",
+			Required: false,
+		})
+
+		collection.AddIndex("idx_gitlab_mr_project_merged_at", false, "project_id, merged_at", "")
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		return backfillMergedAt(app, collection)
+	}, func(app core.App) error {
+		// 回滚：删除合并时间字段和部署、发布表
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		collection.RemoveIndex("idx_gitlab_mr_project_merged_at")
+		if field := collection.Fields.GetByName("merged_at"); field != nil {
+			collection.Fields.RemoveById(field.GetId())
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		collections := []string{
+			"gitlab_releases",
+			"gitlab_deployments",
+		}
+
+		for _, name := range collections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err == nil {
+				if err := app.Delete(collection); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// backfillMergedAt 回填已合并MR的合并时间，优先使用合并事件中的更新时间
+func backfillMergedAt(app core.App, collection *core.Collection) error {
+	records, err := app.FindRecordsByFilter(collection, "state = 'merged'", "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		mergedAt := record.GetDateTime("updated_at").Time()
+
+		event, err := app.FindFirstRecordByFilter(
+			"gitlab_merge_request_events",
+			"merge_request = {:id} && action = 'merge'",
+			dbx.Params{"id": record.Id},
+		)
+		if err == nil {
+			var data struct {
+				ObjectAttributes struct {
+					UpdatedAt string `json:"updated_at"`
+				} `json:"object_attributes"`
+			}
+			if err := event.UnmarshalJSONField("event_data", &data); err == nil {
+				if updatedAt := parseGitLabTime(data.ObjectAttributes.UpdatedAt); !updatedAt.IsZero() {
+					mergedAt = updatedAt
+				}
+			}
+		}
+
+		if mergedAt.IsZero() {
+			continue
+		}
+
+		record.Set("merged_at", mergedAt)
+		if err := app.SaveNoValidate(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createDeploymentsCollection 创建部署集合，按 deployment_id 唯一
+func createDeploymentsCollection(app core.App) error {
+	collection := core.NewBaseCollection("gitlab_deployments")
+	collection.Name = "gitlab_deployments"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "deployment_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "environment",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "environment_tier",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "environment_slug",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "environment_external_url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "status",
+		Required: false,
+	})
+
+	// 状态变化记录，每项为 {status, at}
+	collection.Fields.Add(&core.JSONField{
+		Name:     "status_history",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "ref",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "short_sha",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "commit_url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "commit_title",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "deployable_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "deployable_url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "created_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "status_changed_at",
+		Required: false,
+	})
+
+	// 部署进入终态（success、failed、canceled）的时间
+	collection.Fields.Add(&core.DateField{
+		Name:     "finished_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE UNIQUE INDEX idx_gitlab_deployment_id ON gitlab_deployments (deployment_id)",
+		"CREATE INDEX idx_gitlab_deployment_project_env ON gitlab_deployments (project_id, environment, finished_at)",
+		"CREATE INDEX idx_gitlab_deployment_status ON gitlab_deployments (status)",
+	}
+
+	return app.Save(collection)
+}
+
+// createReleasesCollection 创建发布集合，按 release_id 唯一
+func createReleasesCollection(app core.App) error {
+	collection := core.NewBaseCollection("gitlab_releases")
+	collection.Name = "gitlab_releases"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "release_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "tag",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "description",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "action",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "commit_sha",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "created_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "released_at",
+		Required: false,
+	})
+
+	// 软删除
+	collection.Fields.Add(&core.BoolField{
+		Name:     "deleted",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "deleted_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE UNIQUE INDEX idx_gitlab_release_id ON gitlab_releases (release_id)",
+		"CREATE INDEX idx_gitlab_release_project_tag ON gitlab_releases (project_id, tag)",
+		"CREATE INDEX idx_gitlab_release_released_at ON gitlab_releases (project_id, released_at)",
+	}
+
+	return app.Save(collection)
+}
@@ -0,0 +1,289 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建Wiki页面事件表
+		if err := createWikiPageEventsCollection(app); err != nil {
+			return err
+		}
+
+		// 创建功能开关事件表
+		if err := createFeatureFlagEventsCollection(app); err != nil {
+			return err
+		}
+
+		// 创建表情回应事件表
+		if err := createEmojiEventsCollection(app); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(app core.App) error {
+		// 回滚操作：删除所有相关集合
+		collections := []string{
+			"gitlab_wiki_page_events",
+			"gitlab_feature_flag_events",
+			"gitlab_emoji_events",
+		}
+
+		for _, name := range collections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err == nil {
+				if err := app.Delete(collection); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// createWikiPageEventsCollection 创建Wiki页面事件集合
+func createWikiPageEventsCollection(app core.App) error {
+	collection := core.NewBaseCollection("gitlab_wiki_page_events")
+	collection.Name = "gitlab_wiki_page_events"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "action",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "title",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "slug",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "format",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "message",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "version_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.URLField{
+		Name:     "diff_url",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE INDEX idx_wiki_page_project_slug ON gitlab_wiki_page_events (project_id, slug)",
+		"CREATE INDEX idx_wiki_page_action ON gitlab_wiki_page_events (action)",
+		"CREATE INDEX idx_wiki_page_user_id ON gitlab_wiki_page_events (user_id)",
+	}
+
+	return app.Save(collection)
+}
+
+// createFeatureFlagEventsCollection 创建功能开关事件集合
+func createFeatureFlagEventsCollection(app core.App) error {
+	collection := core.NewBaseCollection("gitlab_feature_flag_events")
+	collection.Name = "gitlab_feature_flag_events"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "flag_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "name",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "description",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.BoolField{
+		Name:     "active",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE INDEX idx_feature_flag_project_flag ON gitlab_feature_flag_events (project_id, flag_id)",
+		"CREATE INDEX idx_feature_flag_name ON gitlab_feature_flag_events (name)",
+		"CREATE INDEX idx_feature_flag_user_id ON gitlab_feature_flag_events (user_id)",
+	}
+
+	return app.Save(collection)
+}
+
+// createEmojiEventsCollection 创建表情回应事件集合
+func createEmojiEventsCollection(app core.App) error {
+	mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+	if err != nil {
+		return err
+	}
+
+	collection := core.NewBaseCollection("gitlab_emoji_events")
+	collection.Name = "gitlab_emoji_events"
+	collection.Type = core.CollectionTypeBase
+	collection.System = false
+
+	// 添加字段
+	collection.Fields.Add(&core.NumberField{
+		Name:     "project_id",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "project_name",
+		Required: false,
+	})
+
+	// award 或 revoke
+	collection.Fields.Add(&core.TextField{
+		Name:     "event_type",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "award_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "name",
+		Required: true,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "awardable_type",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "awardable_id",
+		Required: false,
+	})
+
+	// 回应目标为MR或MR评论时关联MR记录
+	collection.Fields.Add(&core.RelationField{
+		Name:         "merge_request",
+		Required:     false,
+		CollectionId: mrCollection.Id,
+		MaxSelect:    1,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "mr_iid",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "note_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.NumberField{
+		Name:     "user_id",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.TextField{
+		Name:     "user_username",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.DateField{
+		Name:     "awarded_at",
+		Required: false,
+	})
+
+	collection.Fields.Add(&core.JSONField{
+		Name:     "event_data",
+		Required: false,
+	})
+
+	// 添加索引
+	collection.Indexes = []string{
+		"CREATE INDEX idx_emoji_awardable ON gitlab_emoji_events (awardable_type, awardable_id)",
+		"CREATE INDEX idx_emoji_merge_request ON gitlab_emoji_events (merge_request)",
+		"CREATE INDEX idx_emoji_name ON gitlab_emoji_events (name)",
+		"CREATE INDEX idx_emoji_user_id ON gitlab_emoji_events (user_id)",
+	}
+
+	return app.Save(collection)
+}
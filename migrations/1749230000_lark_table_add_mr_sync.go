@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 lark_table collection
+		tableCollection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		// 添加MR字段映射：飞书字段名 -> gitlab_merge_requests 字段名
+		tableCollection.Fields.Add(&core.JSONField{
+			Name:     "mr_field_mapping",
+			Required: false,
+		})
+
+		tableCollection.AddIndex("idx_lark_table_gitlab_project_id", false, "gitlab_project_id", "")
+
+		if err := app.Save(tableCollection); err != nil {
+			return err
+		}
+
+		// 获取 gitlab_merge_requests collection
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		// 添加已同步的飞书记录：lark_table 记录ID -> 飞书 record_id
+		mrCollection.Fields.Add(&core.JSONField{
+			Name:     "lark_record_ids",
+			Required: false,
+		})
+
+		return app.Save(mrCollection)
+	}, func(app core.App) error {
+		// 回滚：删除新增字段
+		tableCollection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		tableCollection.RemoveIndex("idx_lark_table_gitlab_project_id")
+		if field := tableCollection.Fields.GetByName("mr_field_mapping"); field != nil {
+			tableCollection.Fields.RemoveById(field.GetId())
+		}
+
+		if err := app.Save(tableCollection); err != nil {
+			return err
+		}
+
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		if field := mrCollection.Fields.GetByName("lark_record_ids"); field != nil {
+			mrCollection.Fields.RemoveById(field.GetId())
+		}
+
+		return app.Save(mrCollection)
+	})
+}
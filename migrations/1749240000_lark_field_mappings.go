@@ -0,0 +1,203 @@
+package migrations
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// mrFieldMappingConversions mr_field_mapping 中的MR字段对应的 source_path、飞书字段类型和转换
+//
+// 事件中没有或需要回退取值的字段（作者、流水线状态、合并时间）使用 $record 路径读取MR记录已保存的字段，与原同步逻辑一致。
+var mrFieldMappingConversions = map[string][3]string{
+	"mr_id":            {"$.object_attributes.id", "number", ""},
+	"mr_iid":           {"$.object_attributes.iid", "number", ""},
+	"project_id":       {"$.project.id", "number", ""},
+	"project_name":     {"$.project.name", "text", ""},
+	"title":            {"$.object_attributes.title", "text", ""},
+	"description":      {"$.object_attributes.description", "text", ""},
+	"state":            {"$.object_attributes.state", "text", ""},
+	"action":           {"$.object_attributes.action", "text", ""},
+	"source_branch":    {"$.object_attributes.source_branch", "text", ""},
+	"target_branch":    {"$.object_attributes.target_branch", "text", ""},
+	"url":              {"$.object_attributes.url", "text", ""},
+	"merge_commit_sha": {"$.object_attributes.merge_commit_sha", "text", ""},
+	"created_at":       {"$.object_attributes.created_at", "date", "date"},
+	"updated_at":       {"$.object_attributes.updated_at", "date", "date"},
+	"author_name":      {"$record.author_name", "text", ""},
+	"author_username":  {"$record.author_username", "text", ""},
+	"pipeline_status":  {"$record.pipeline_status", "text", ""},
+	"merged_at":        {"$record.merged_at", "date", "date"},
+}
+
+// convertMRFieldMapping 将 mr_field_mapping（飞书字段名 -> MR字段名）转换为各飞书字段的 source_path、字段类型和转换，
+// 返回按飞书字段名排序的无法转换的条目
+func convertMRFieldMapping(mapping map[string]string) (map[string][3]string, []string) {
+	converted := make(map[string][3]string, len(mapping))
+	var unconvertible []string
+	for targetField, mrField := range mapping {
+		conversion, ok := mrFieldMappingConversions[mrField]
+		if !ok {
+			unconvertible = append(unconvertible, fmt.Sprintf("%q -> %q", targetField, mrField))
+			continue
+		}
+		converted[targetField] = conversion
+	}
+	sort.Strings(unconvertible)
+	return converted, unconvertible
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		tableCollection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		// 创建 lark_field_mappings 集合，声明 GitLab 数据到飞书多维表格字段的映射
+		collection := core.NewBaseCollection("lark_field_mappings")
+
+		// 配置集合基本信息
+		collection.Name = "lark_field_mappings"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.RelationField{
+			Name:          "lark_table",
+			Required:      true,
+			CollectionId:  tableCollection.Id,
+			MaxSelect:     1,
+			CascadeDelete: true,
+		})
+
+		// 数据来源
+		collection.Fields.Add(&core.SelectField{
+			Name:      "source",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"merge_request"},
+		})
+
+		// 来源记录 event_data 中的 JSONPath，例如 $.object_attributes.title
+		collection.Fields.Add(&core.TextField{
+			Name:     "source_path",
+			Required: true,
+		})
+
+		// 飞书多维表格中的字段名
+		collection.Fields.Add(&core.TextField{
+			Name:     "target_field",
+			Required: true,
+		})
+
+		// 飞书多维表格字段类型，决定写入值的格式
+		collection.Fields.Add(&core.SelectField{
+			Name:      "field_type",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"text", "number", "single_select", "multi_select", "date", "checkbox", "user", "url"},
+		})
+
+		// 取值后的转换，为空时不转换
+		collection.Fields.Add(&core.SelectField{
+			Name:      "transform",
+			Required:  false,
+			MaxSelect: 1,
+			Values:    []string{"user", "date", "url", "label"},
+		})
+
+		// 转换参数：users（GitLab用户名 -> 飞书 open_id）、labels（源值 -> 飞书选项）、text / text_path（链接文本）
+		collection.Fields.Add(&core.JSONField{
+			Name:     "options",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_lark_field_mappings_target ON lark_field_mappings (lark_table, source, target_field)",
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 将 lark_table 中已有的 mr_field_mapping 转换为映射记录
+		tables, err := app.FindAllRecords("lark_table")
+		if err != nil {
+			return err
+		}
+
+		// 无法转换的条目会导致迁移失败并回滚，避免删除字段时丢失配置
+		var unconvertible []string
+		for _, table := range tables {
+			raw := strings.TrimSpace(table.GetString("mr_field_mapping"))
+			if raw == "" || raw == "null" {
+				continue
+			}
+
+			mapping := map[string]string{}
+			if err := table.UnmarshalJSONField("mr_field_mapping", &mapping); err != nil {
+				unconvertible = append(unconvertible, fmt.Sprintf("lark_table %s: invalid mr_field_mapping %s", table.Id, raw))
+				continue
+			}
+
+			converted, invalid := convertMRFieldMapping(mapping)
+			for _, entry := range invalid {
+				unconvertible = append(unconvertible, fmt.Sprintf("lark_table %s: %s", table.Id, entry))
+			}
+
+			for targetField, conversion := range converted {
+				record := core.NewRecord(collection)
+				record.Set("lark_table", table.Id)
+				record.Set("source", "merge_request")
+				record.Set("source_path", conversion[0])
+				record.Set("target_field", targetField)
+				record.Set("field_type", conversion[1])
+				record.Set("transform", conversion[2])
+
+				if err := app.Save(record); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(unconvertible) > 0 {
+			sort.Strings(unconvertible)
+			return fmt.Errorf("mr_field_mapping entries cannot be converted to lark_field_mappings, fix or remove them and rerun the migration:\n%s",
+				strings.Join(unconvertible, "\n"))
+		}
+
+		// 删除被替代的 mr_field_mapping 字段
+		if field := tableCollection.Fields.GetByName("mr_field_mapping"); field != nil {
+			tableCollection.Fields.RemoveById(field.GetId())
+		}
+
+		return app.Save(tableCollection)
+	}, func(app core.App) error {
+		// 回滚：恢复 mr_field_mapping 字段并删除 lark_field_mappings 集合
+		tableCollection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		tableCollection.Fields.Add(&core.JSONField{
+			Name:     "mr_field_mapping",
+			Required: false,
+		})
+
+		if err := app.Save(tableCollection); err != nil {
+			return err
+		}
+
+		collection, err := app.FindCollectionByNameOrId("lark_field_mappings")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
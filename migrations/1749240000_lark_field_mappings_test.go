@@ -0,0 +1,138 @@
+package migrations
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+// readmeMRFieldMapping 原同步文档中的 mr_field_mapping 示例
+const readmeMRFieldMapping = `{"标题": "title", "状态": "state", "作者": "author_username", "链接": "url", "更新时间": "updated_at"}`
+
+func TestConvertMRFieldMapping(t *testing.T) {
+	cases := []struct {
+		name          string
+		mapping       map[string]string
+		converted     map[string][3]string
+		unconvertible []string
+	}{
+		{
+			"event fields",
+			map[string]string{"标题": "title", "更新时间": "updated_at"},
+			map[string][3]string{
+				"标题":   {"$.object_attributes.title", "text", ""},
+				"更新时间": {"$.object_attributes.updated_at", "date", "date"},
+			},
+			nil,
+		},
+		{
+			"stored record fields",
+			map[string]string{"作者": "author_username", "作者姓名": "author_name", "流水线": "pipeline_status", "合并时间": "merged_at"},
+			map[string][3]string{
+				"作者":   {"$record.author_username", "text", ""},
+				"作者姓名": {"$record.author_name", "text", ""},
+				"流水线":  {"$record.pipeline_status", "text", ""},
+				"合并时间": {"$record.merged_at", "date", "date"},
+			},
+			nil,
+		},
+		{
+			"unknown fields",
+			map[string]string{"标题": "title", "B": "nope", "A": "lark_record_ids"},
+			map[string][3]string{"标题": {"$.object_attributes.title", "text", ""}},
+			[]string{`"A" -> "lark_record_ids"`, `"B" -> "nope"`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			converted, unconvertible := convertMRFieldMapping(tc.mapping)
+			if len(converted) != len(tc.converted) {
+				t.Fatalf("expected %d converted entries, got %v", len(tc.converted), converted)
+			}
+			for targetField, expected := range tc.converted {
+				if converted[targetField] != expected {
+					t.Fatalf("expected %q to convert to %v, got %v", targetField, expected, converted[targetField])
+				}
+			}
+			if !slices.Equal(unconvertible, tc.unconvertible) {
+				t.Fatalf("expected unconvertible %v, got %v", tc.unconvertible, unconvertible)
+			}
+		})
+	}
+}
+
+func TestLarkFieldMappingsMigrationConvertsReadmeExample(t *testing.T) {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	// 回滚到 lark_field_mappings 迁移之前，写入旧格式的配置后重新执行
+	runner := core.NewMigrationsRunner(app, core.AppMigrations)
+	reverted := 0
+	for _, migration := range core.AppMigrations.Items() {
+		if strings.Split(migration.File, "_")[0] >= "1749240000" {
+			reverted++
+		}
+	}
+	if _, err := runner.Down(reverted); err != nil {
+		t.Fatal(err)
+	}
+
+	tableCollection, err := app.FindCollectionByNameOrId("lark_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := core.NewRecord(tableCollection)
+	table.Set("table_id", "tblREADME")
+	table.Set("mr_field_mapping", readmeMRFieldMapping)
+	if err := app.SaveNoValidate(table); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runner.Up(); err != nil {
+		t.Fatalf("expected the README mr_field_mapping to migrate, got %v", err)
+	}
+
+	records, err := app.FindAllRecords("lark_field_mappings")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := map[string]string{}
+	for _, record := range records {
+		if record.GetString("lark_table") != table.Id {
+			t.Fatalf("mapping %s is not linked to the migrated table", record.Id)
+		}
+		paths[record.GetString("target_field")] = record.GetString("source_path")
+	}
+
+	expected := map[string]string{
+		"标题":   "$.object_attributes.title",
+		"状态":   "$.object_attributes.state",
+		"作者":   "$record.author_username",
+		"链接":   "$.object_attributes.url",
+		"更新时间": "$.object_attributes.updated_at",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("expected %d mappings, got %v", len(expected), paths)
+	}
+	for targetField, path := range expected {
+		if paths[targetField] != path {
+			t.Fatalf("expected %q to map from %q, got %q", targetField, path, paths[targetField])
+		}
+	}
+
+	tableCollection, err = app.FindCollectionByNameOrId("lark_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tableCollection.Fields.GetByName("mr_field_mapping") != nil {
+		t.Fatal("expected mr_field_mapping to be removed after the conversion")
+	}
+}
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 字段映射增加 issue 来源
+		mappingsCollection, err := app.FindCollectionByNameOrId("lark_field_mappings")
+		if err != nil {
+			return err
+		}
+
+		if field, ok := mappingsCollection.Fields.GetByName("source").(*core.SelectField); ok {
+			field.Values = []string{"merge_request", "issue"}
+		}
+
+		if err := app.Save(mappingsCollection); err != nil {
+			return err
+		}
+
+		// 获取 gitlab_issues collection
+		issuesCollection, err := app.FindCollectionByNameOrId("gitlab_issues")
+		if err != nil {
+			return err
+		}
+
+		// 添加已同步的飞书记录：lark_table 记录ID -> 飞书 record_id
+		issuesCollection.Fields.Add(&core.JSONField{
+			Name:     "lark_record_ids",
+			Required: false,
+		})
+
+		return app.Save(issuesCollection)
+	}, func(app core.App) error {
+		// 回滚：删除 issue 来源的映射及新增字段
+		mappings, err := app.FindRecordsByFilter("lark_field_mappings", "source = 'issue'", "", 0, 0, dbx.Params{})
+		if err != nil {
+			return err
+		}
+
+		for _, mapping := range mappings {
+			if err := app.Delete(mapping); err != nil {
+				return err
+			}
+		}
+
+		mappingsCollection, err := app.FindCollectionByNameOrId("lark_field_mappings")
+		if err != nil {
+			return err
+		}
+
+		if field, ok := mappingsCollection.Fields.GetByName("source").(*core.SelectField); ok {
+			field.Values = []string{"merge_request"}
+		}
+
+		if err := app.Save(mappingsCollection); err != nil {
+			return err
+		}
+
+		issuesCollection, err := app.FindCollectionByNameOrId("gitlab_issues")
+		if err != nil {
+			return err
+		}
+
+		if field := issuesCollection.Fields.GetByName("lark_record_ids"); field != nil {
+			issuesCollection.Fields.RemoveById(field.GetId())
+		}
+
+		return app.Save(issuesCollection)
+	})
+}
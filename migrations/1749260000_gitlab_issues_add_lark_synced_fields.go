@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 gitlab_issues collection
+		collection, err := app.FindCollectionByNameOrId("gitlab_issues")
+		if err != nil {
+			return err
+		}
+
+		// 最近一次同步写入飞书的字段值：lark_table 记录ID -> 字段值，用于识别飞书回写事件中同步自身的写入
+		collection.Fields.Add(&core.JSONField{
+			Name:     "lark_synced_fields",
+			Required: false,
+		})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚：删除 lark_synced_fields 字段
+		collection, err := app.FindCollectionByNameOrId("gitlab_issues")
+		if err != nil {
+			return err
+		}
+
+		if field := collection.Fields.GetByName("lark_synced_fields"); field != nil {
+			collection.Fields.RemoveById(field.GetId())
+		}
+
+		return app.Save(collection)
+	})
+}
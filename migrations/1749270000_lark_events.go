@@ -0,0 +1,104 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建 lark_events 集合，按 event_id 记录飞书事件回调用于审计和去重
+		collection := core.NewBaseCollection("lark_events")
+
+		// 配置集合基本信息
+		collection.Name = "lark_events"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.TextField{
+			Name:     "event_id",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "event_type",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "schema",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "app_id",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "tenant_key",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "create_time",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "received_at",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.SelectField{
+			Name:      "status",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"processing", "processed", "failed"},
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "response_status",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "error",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "attempts",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "processed_at",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.JSONField{
+			Name:     "event_data",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_lark_event_event_id ON lark_events (event_id)",
+			"CREATE INDEX idx_lark_event_event_type ON lark_events (event_type)",
+			"CREATE INDEX idx_lark_event_received_at ON lark_events (received_at)",
+			"CREATE INDEX idx_lark_event_status ON lark_events (status)",
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚操作：删除 lark_events 集合
+		collection, err := app.FindCollectionByNameOrId("lark_events")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
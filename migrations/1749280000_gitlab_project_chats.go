@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建 gitlab_project_chats 集合，配置接收MR卡片通知的飞书群
+		collection := core.NewBaseCollection("gitlab_project_chats")
+
+		// 配置集合基本信息
+		collection.Name = "gitlab_project_chats"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.NumberField{
+			Name:     "project_id",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "project_name",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "chat_id",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.BoolField{
+			Name:     "enabled",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_gitlab_project_chat_project_id ON gitlab_project_chats (project_id)",
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 获取 gitlab_merge_requests collection
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		// 是否存在未解决的阻塞讨论
+		mrCollection.Fields.Add(&core.BoolField{
+			Name:     "has_blocking_discussions",
+			Required: false,
+		})
+
+		// 已发送的飞书卡片消息ID，MR状态变化时原地更新该卡片
+		mrCollection.Fields.Add(&core.TextField{
+			Name:     "lark_card_message_id",
+			Required: false,
+		})
+
+		return app.Save(mrCollection)
+	}, func(app core.App) error {
+		// 回滚：删除新增字段和 gitlab_project_chats 集合
+		mrCollection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"has_blocking_discussions", "lark_card_message_id"} {
+			if field := mrCollection.Fields.GetByName(name); field != nil {
+				mrCollection.Fields.RemoveById(field.GetId())
+			}
+		}
+
+		if err := app.Save(mrCollection); err != nil {
+			return err
+		}
+
+		collection, err := app.FindCollectionByNameOrId("gitlab_project_chats")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,122 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建 notification_rules 集合，按事件条件把GitLab事件通知路由到飞书
+		collection := core.NewBaseCollection("notification_rules")
+
+		// 配置集合基本信息
+		collection.Name = "notification_rules"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		eventTypes := []string{
+			"Merge Request Hook",
+			"Push Hook",
+			"Tag Push Hook",
+			"Issues Hook",
+			"Confidential Issues Hook",
+			"Note Hook",
+			"Pipeline Hook",
+			"Job Hook",
+			"Deployment Hook",
+			"Release Hook",
+			"Wiki Page Hook",
+			"Feature Flag Hook",
+			"Emoji Hook",
+			"System Hook",
+		}
+
+		// 添加字段
+		collection.Fields.Add(&core.TextField{
+			Name:     "name",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.BoolField{
+			Name:     "enabled",
+			Required: false,
+		})
+
+		// 匹配条件，未配置的条件视为不限
+		collection.Fields.Add(&core.SelectField{
+			Name:      "event_types",
+			Required:  false,
+			MaxSelect: len(eventTypes),
+			Values:    eventTypes,
+		})
+
+		collection.Fields.Add(&core.JSONField{
+			Name:     "project_ids",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "namespace",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "branch",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.JSONField{
+			Name:     "labels",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.JSONField{
+			Name:     "authors",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.JSONField{
+			Name:     "actions",
+			Required: false,
+		})
+
+		// 通知目标
+		collection.Fields.Add(&core.SelectField{
+			Name:      "target_type",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"chat", "user", "webhook"},
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "target",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "webhook_secret",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "template",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE INDEX idx_notification_rule_enabled ON notification_rules (enabled)",
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚操作：删除 notification_rules 集合
+		collection, err := app.FindCollectionByNameOrId("notification_rules")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
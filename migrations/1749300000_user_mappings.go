@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 创建 user_mappings 集合，关联GitLab用户和飞书用户
+		collection := core.NewBaseCollection("user_mappings")
+
+		// 配置集合基本信息
+		collection.Name = "user_mappings"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.NumberField{
+			Name:     "gitlab_user_id",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "gitlab_username",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "gitlab_name",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.EmailField{
+			Name:     "email",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "lark_open_id",
+			Required: false,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "lark_user_id",
+			Required: false,
+		})
+
+		// 来源：auto 为按邮箱自动匹配，manual 为手动维护，手动维护的飞书身份不会被自动匹配覆盖
+		collection.Fields.Add(&core.SelectField{
+			Name:      "source",
+			Required:  true,
+			MaxSelect: 1,
+			Values:    []string{"auto", "manual"},
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "matched_at",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_user_mapping_gitlab_username ON user_mappings (gitlab_username)",
+			"CREATE INDEX idx_user_mapping_gitlab_user_id ON user_mappings (gitlab_user_id)",
+			"CREATE INDEX idx_user_mapping_lark_open_id ON user_mappings (lark_open_id)",
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚操作：删除 user_mappings 集合
+		collection, err := app.FindCollectionByNameOrId("user_mappings")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
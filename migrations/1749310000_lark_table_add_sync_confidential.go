@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 lark_table collection
+		collection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		// 是否同步机密Issue，默认不同步，避免能查看多维表格的用户看到受限的Issue内容
+		collection.Fields.Add(&core.BoolField{
+			Name:     "sync_confidential_issues",
+			Required: false,
+		})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚：删除新增字段
+		collection, err := app.FindCollectionByNameOrId("lark_table")
+		if err != nil {
+			return err
+		}
+
+		if field := collection.Fields.GetByName("sync_confidential_issues"); field != nil {
+			collection.Fields.RemoveById(field.GetId())
+		}
+
+		return app.Save(collection)
+	})
+}
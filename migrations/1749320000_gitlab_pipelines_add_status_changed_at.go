@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 gitlab_pipelines collection
+		collection, err := app.FindCollectionByNameOrId("gitlab_pipelines")
+		if err != nil {
+			return err
+		}
+
+		// 事件中最近一次状态变化的时间，用于忽略乱序到达的旧状态
+		collection.Fields.Add(&core.DateField{
+			Name:     "status_changed_at",
+			Required: false,
+		})
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 回填：已结束的流水线取结束时间，其余取创建时间
+		records, err := app.FindAllRecords(collection)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			changedAt := record.GetDateTime("finished_at")
+			if changedAt.IsZero() {
+				changedAt = record.GetDateTime("created_at")
+			}
+			if changedAt.IsZero() {
+				continue
+			}
+
+			record.Set("status_changed_at", changedAt)
+			if err := app.SaveNoValidate(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		// 回滚：删除新增字段
+		collection, err := app.FindCollectionByNameOrId("gitlab_pipelines")
+		if err != nil {
+			return err
+		}
+
+		if field := collection.Fields.GetByName("status_changed_at"); field != nil {
+			collection.Fields.RemoveById(field.GetId())
+		}
+
+		return app.Save(collection)
+	})
+}
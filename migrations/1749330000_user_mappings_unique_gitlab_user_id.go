@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// 获取 user_mappings collection
+		collection, err := app.FindCollectionByNameOrId("user_mappings")
+		if err != nil {
+			return err
+		}
+
+		// 合并同一GitLab用户ID的重复映射，之后才能添加唯一索引
+		if err := dedupeUserMappings(app, collection); err != nil {
+			return err
+		}
+
+		// 用户名被其他GitLab用户占用时会清空旧映射的用户名，改为非必填
+		if field, ok := collection.Fields.GetByName("gitlab_username").(*core.TextField); ok {
+			field.Required = false
+		}
+
+		// 用户ID和用户名各自唯一，未记录的空值不参与唯一约束
+		collection.RemoveIndex("idx_user_mapping_gitlab_user_id")
+		collection.RemoveIndex("idx_user_mapping_gitlab_username")
+		collection.AddIndex("idx_user_mapping_gitlab_user_id", true, "gitlab_user_id", "gitlab_user_id > 0")
+		collection.AddIndex("idx_user_mapping_gitlab_username", true, "gitlab_username", "gitlab_username != ''")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// 回滚：恢复非唯一的用户ID索引和必填的用户名，存在已清空用户名的映射时回滚失败
+		collection, err := app.FindCollectionByNameOrId("user_mappings")
+		if err != nil {
+			return err
+		}
+
+		if field, ok := collection.Fields.GetByName("gitlab_username").(*core.TextField); ok {
+			field.Required = true
+		}
+
+		collection.RemoveIndex("idx_user_mapping_gitlab_user_id")
+		collection.RemoveIndex("idx_user_mapping_gitlab_username")
+		collection.AddIndex("idx_user_mapping_gitlab_username", true, "gitlab_username", "")
+		collection.AddIndex("idx_user_mapping_gitlab_user_id", false, "gitlab_user_id", "")
+
+		return app.Save(collection)
+	})
+}
+
+// dedupeUserMappings 每个GitLab用户ID只保留一行映射
+//
+// 优先保留手动维护的映射，其次是已匹配飞书身份的映射，再次是最近匹配飞书的映射；
+// 保留行缺少的飞书身份和邮箱从被删除的行补齐。
+func dedupeUserMappings(app core.App, collection *core.Collection) error {
+	records, err := app.FindRecordsByFilter(collection, "gitlab_user_id > 0", "-matched_at", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	groups := map[int][]*core.Record{}
+	var order []int
+	for _, record := range records {
+		userID := record.GetInt("gitlab_user_id")
+		if _, ok := groups[userID]; !ok {
+			order = append(order, userID)
+		}
+		groups[userID] = append(groups[userID], record)
+	}
+
+	rank := func(record *core.Record) int {
+		switch {
+		case record.GetString("source") == "manual":
+			return 2
+		case record.GetString("lark_open_id") != "":
+			return 1
+		}
+		return 0
+	}
+
+	for _, userID := range order {
+		group := groups[userID]
+		if len(group) < 2 {
+			continue
+		}
+
+		// 同级时保留最近匹配的行（records 已按匹配时间倒序）
+		kept := group[0]
+		for _, record := range group[1:] {
+			if rank(record) > rank(kept) {
+				kept = record
+			}
+		}
+
+		for _, record := range group {
+			if record.Id == kept.Id {
+				continue
+			}
+			for _, name := range []string{"lark_open_id", "lark_user_id", "email", "gitlab_name"} {
+				if kept.GetString(name) == "" && record.GetString(name) != "" {
+					kept.Set(name, record.GetString(name))
+				}
+			}
+			if err := app.Delete(record); err != nil {
+				return err
+			}
+		}
+
+		if err := app.SaveNoValidate(kept); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		commitsCollection, err := app.FindCollectionByNameOrId("gitlab_commits")
+		if err != nil {
+			return err
+		}
+
+		pushCollection, err := app.FindCollectionByNameOrId("gitlab_push_events")
+		if err != nil {
+			return err
+		}
+
+		// 创建 gitlab_commit_refs 集合，记录提交被推送到的每个引用
+		collection := core.NewBaseCollection("gitlab_commit_refs")
+
+		// 配置集合基本信息
+		collection.Name = "gitlab_commit_refs"
+		collection.Type = core.CollectionTypeBase
+		collection.System = false
+
+		// 添加字段
+		collection.Fields.Add(&core.RelationField{
+			Name:          "commit",
+			Required:      true,
+			CollectionId:  commitsCollection.Id,
+			MaxSelect:     1,
+			CascadeDelete: true,
+		})
+
+		collection.Fields.Add(&core.NumberField{
+			Name:     "project_id",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "sha",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "ref",
+			Required: true,
+		})
+
+		collection.Fields.Add(&core.TextField{
+			Name:     "branch",
+			Required: false,
+		})
+
+		// 提交首次出现在该引用上的推送事件
+		collection.Fields.Add(&core.RelationField{
+			Name:         "push_event",
+			Required:     false,
+			CollectionId: pushCollection.Id,
+			MaxSelect:    1,
+		})
+
+		collection.Fields.Add(&core.DateField{
+			Name:     "pushed_at",
+			Required: false,
+		})
+
+		// 添加索引
+		collection.Indexes = []string{
+			"CREATE UNIQUE INDEX idx_commit_ref_project_sha_ref ON gitlab_commit_refs (project_id, sha, ref)",
+			"CREATE INDEX idx_commit_ref_project_branch_pushed ON gitlab_commit_refs (project_id, branch, pushed_at)",
+			"CREATE INDEX idx_commit_ref_commit ON gitlab_commit_refs (`commit`)",
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// 回填：已有提交的首次推送引用
+		commits, err := app.FindAllRecords(commitsCollection)
+		if err != nil {
+			return err
+		}
+
+		for _, commit := range commits {
+			if commit.GetString("ref") == "" {
+				continue
+			}
+
+			record := core.NewRecord(collection)
+			record.Set("commit", commit.Id)
+			record.Set("project_id", commit.GetInt("project_id"))
+			record.Set("sha", commit.GetString("sha"))
+			record.Set("ref", commit.GetString("ref"))
+			record.Set("branch", commit.GetString("branch"))
+			record.Set("push_event", commit.GetString("push_event"))
+			record.Set("pushed_at", commit.GetDateTime("pushed_at"))
+
+			if err := app.SaveNoValidate(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		// 回滚操作：删除 gitlab_commit_refs 集合
+		collection, err := app.FindCollectionByNameOrId("gitlab_commit_refs")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
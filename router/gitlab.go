@@ -7,7 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 	"gitlab.yogorobot.com/sre/lark-base-mapping/middlewares"
 )
 
@@ -21,14 +23,22 @@ func (ft *FlexibleTime) UnmarshalJSON(data []byte) error {
 	// 移除引号
 	timeStr := strings.Trim(string(data), `"`)
 
+	// 空值或 null 保持零值
+	if timeStr == "" || timeStr == "null" {
+		ft.Time = time.Time{}
+		return nil
+	}
+
 	// 尝试多种时间格式
 	formats := []string{
-		time.RFC3339,              // 2006-01-02T15:04:05Z07:00
-		time.RFC3339Nano,          // 2006-01-02T15:04:05.999999999Z07:00
-		"2006-01-02T15:04:05Z",    // 2006-01-02T15:04:05Z
-		"2006-01-02T15:04:05",     // 2006-01-02T15:04:05
-		"2006-01-02 15:04:05 UTC", // 2006-01-02 15:04:05 UTC (GitLab格式)
-		"2006-01-02 15:04:05",     // 2006-01-02 15:04:05
+		time.RFC3339,                // 2006-01-02T15:04:05Z07:00
+		time.RFC3339Nano,            // 2006-01-02T15:04:05.999999999Z07:00
+		"2006-01-02T15:04:05Z",      // 2006-01-02T15:04:05Z
+		"2006-01-02T15:04:05",       // 2006-01-02T15:04:05
+		"2006-01-02 15:04:05 UTC",   // 2006-01-02 15:04:05 UTC (GitLab格式)
+		"2006-01-02 15:04:05",       // 2006-01-02 15:04:05
+		"2006-01-02 15:04:05 -0700", // 2006-01-02 15:04:05 +0800 (Deployment Hook)
+		"2006-01-02",                // 2006-01-02 (Issue due_date)
 	}
 
 	var err error
@@ -69,28 +79,28 @@ type SystemHookMergeRequestEvent struct {
 
 // SystemHookMergeRequestAttributes System Hook格式的MR属性
 type SystemHookMergeRequestAttributes struct {
-	ID                          int     `json:"id"`
-	IID                         int     `json:"iid"`
-	Title                       string  `json:"title"`
-	Description                 string  `json:"description"`
-	State                       string  `json:"state"`
-	CreatedAt                   string  `json:"created_at"` // System Hook使用字符串格式
-	UpdatedAt                   string  `json:"updated_at"` // System Hook使用字符串格式
-	MergeStatus                 string  `json:"merge_status"`
-	TargetBranch                string  `json:"target_branch"`
-	SourceBranch                string  `json:"source_branch"`
-	SourceProjectID             int     `json:"source_project_id"`
-	TargetProjectID             int     `json:"target_project_id"`
-	URL                         string  `json:"url"`
-	Source                      Project `json:"source"`
-	Target                      Project `json:"target"`
-	LastCommit                  Commit  `json:"last_commit"`
-	WorkInProgress              bool    `json:"work_in_progress"`
-	Assignee                    User    `json:"assignee"`
-	Author                      User    `json:"author"`
-	MergeCommitSHA              string  `json:"merge_commit_sha"`
-	BlockingDiscussionsResolved bool    `json:"blocking_discussions_resolved"`
-	Action                      string  `json:"action"`
+	ID                          int          `json:"id"`
+	IID                         int          `json:"iid"`
+	Title                       string       `json:"title"`
+	Description                 string       `json:"description"`
+	State                       string       `json:"state"`
+	CreatedAt                   FlexibleTime `json:"created_at"` // System Hook使用 "2006-01-02 15:04:05 UTC" 格式
+	UpdatedAt                   FlexibleTime `json:"updated_at"` // System Hook使用 "2006-01-02 15:04:05 UTC" 格式
+	MergeStatus                 string       `json:"merge_status"`
+	TargetBranch                string       `json:"target_branch"`
+	SourceBranch                string       `json:"source_branch"`
+	SourceProjectID             int          `json:"source_project_id"`
+	TargetProjectID             int          `json:"target_project_id"`
+	URL                         string       `json:"url"`
+	Source                      Project      `json:"source"`
+	Target                      Project      `json:"target"`
+	LastCommit                  Commit       `json:"last_commit"`
+	WorkInProgress              bool         `json:"work_in_progress"`
+	Assignee                    User         `json:"assignee"`
+	Author                      User         `json:"author"`
+	MergeCommitSHA              string       `json:"merge_commit_sha"`
+	BlockingDiscussionsResolved *bool        `json:"blocking_discussions_resolved"` // 较旧的GitLab版本和部分 System Hook 负载不包含该字段
+	Action                      string       `json:"action"`
 }
 
 // SystemHookChanges System Hook格式的变更信息
@@ -100,6 +110,8 @@ type SystemHookChanges struct {
 	Labels      SystemHookLabelChange `json:"labels"`
 	State       SystemHookChange      `json:"state"`
 	UpdatedAt   SystemHookChange      `json:"updated_at"`
+	Assignees   SystemHookUserChange  `json:"assignees"`
+	Reviewers   SystemHookUserChange  `json:"reviewers"`
 }
 
 // SystemHookChange System Hook格式的单个变更
@@ -114,6 +126,12 @@ type SystemHookLabelChange struct {
 	Current  []Label `json:"current"`
 }
 
+// SystemHookUserChange System Hook格式的指派人或评审人变更
+type SystemHookUserChange struct {
+	Previous []User `json:"previous"`
+	Current  []User `json:"current"`
+}
+
 type User struct {
 	ID        int    `json:"id"`
 	Name      string `json:"name"`
@@ -161,7 +179,7 @@ type MergeRequestAttributes struct {
 	Assignee                    User         `json:"assignee"`
 	Author                      User         `json:"author"`
 	MergeCommitSHA              string       `json:"merge_commit_sha"`
-	BlockingDiscussionsResolved bool         `json:"blocking_discussions_resolved"`
+	BlockingDiscussionsResolved *bool        `json:"blocking_discussions_resolved"` // 较旧的GitLab版本和部分 System Hook 负载不包含该字段
 	Action                      string       `json:"action"`
 }
 
@@ -184,6 +202,8 @@ type Changes struct {
 	Labels      LabelChange `json:"labels"`
 	State       Change      `json:"state"`
 	UpdatedAt   Change      `json:"updated_at"`
+	Assignees   UserChange  `json:"assignees"`
+	Reviewers   UserChange  `json:"reviewers"`
 }
 
 type Change struct {
@@ -196,6 +216,12 @@ type LabelChange struct {
 	Current  []Label `json:"current"`
 }
 
+// UserChange 指派人或评审人变更
+type UserChange struct {
+	Previous []User `json:"previous"`
+	Current  []User `json:"current"`
+}
+
 type Repository struct {
 	Name        string `json:"name"`
 	URL         string `json:"url"`
@@ -206,9 +232,13 @@ type Repository struct {
 type Commit struct {
 	ID        string       `json:"id"`
 	Message   string       `json:"message"`
+	Title     string       `json:"title"`
 	Timestamp FlexibleTime `json:"timestamp"`
 	URL       string       `json:"url"`
 	Author    Author       `json:"author"`
+	Added     []string     `json:"added"`
+	Modified  []string     `json:"modified"`
+	Removed   []string     `json:"removed"`
 }
 
 type Author struct {
@@ -250,6 +280,11 @@ type UserSystemHookEvent struct {
 	UserUsername string       `json:"user_username"`
 	UserID       int          `json:"user_id"`
 	OldUsername  string       `json:"old_username,omitempty"` // for user_rename
+
+	// user_create、user_destroy、user_rename 使用不带 user_ 前缀的字段
+	Name     string `json:"name,omitempty"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
 }
 
 // Group System Hook Events
@@ -382,8 +417,22 @@ func GitLabWebhook(e *core.RequestEvent) error {
 		return handlePushEvent(e, body)
 	case "Tag Push Hook":
 		return handleTagPushEvent(e, body)
-	case "Issues Hook":
+	case "Issues Hook", "Confidential Issues Hook":
 		return handleIssuesEvent(e, body)
+	case "Pipeline Hook":
+		return handlePipelineEvent(e, body)
+	case "Job Hook":
+		return handleJobEvent(e, body)
+	case "Deployment Hook":
+		return handleDeploymentEvent(e, body)
+	case "Release Hook":
+		return handleReleaseEvent(e, body)
+	case "Wiki Page Hook":
+		return handleWikiPageEvent(e, body)
+	case "Feature Flag Hook":
+		return handleFeatureFlagEvent(e, body)
+	case "Emoji Hook":
+		return handleEmojiEvent(e, body)
 	default:
 		app.Logger().Info("Unsupported GitLab event type", "eventType", eventType)
 		return e.JSON(http.StatusOK, map[string]interface{}{
@@ -535,6 +584,14 @@ func handleProjectSystemEvent(e *core.RequestEvent, body []byte, eventName strin
 		}
 	}
 
+	dispatchNotification(e, notificationEvent{
+		EventType: "System Hook",
+		Action:    eventName,
+		ProjectID: event.ProjectID,
+		Namespace: event.PathWithNamespace,
+		Title:     event.Name,
+	}, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Project system event processed",
@@ -556,6 +613,9 @@ func handleUserSystemEvent(e *core.RequestEvent, body []byte, eventName string)
 		app.Logger().Error("Failed to parse user system event", "error", err, "eventName", eventName)
 		return e.BadRequestError("Invalid user system event format", err)
 	}
+	if event.UserUsername == "" {
+		event.UserName, event.UserUsername, event.UserEmail = event.Name, event.Username, event.Email
+	}
 
 	app.Logger().Info("Processing user system event",
 		"eventName", eventName,
@@ -588,6 +648,23 @@ func handleUserSystemEvent(e *core.RequestEvent, body []byte, eventName string)
 		}
 	}
 
+	// 新建或改名的用户同步到 user_mappings
+	if eventName == "user_create" || eventName == "user_rename" {
+		syncUserMapping(e, User{
+			ID:       event.UserID,
+			Name:     event.UserName,
+			Username: event.UserUsername,
+			Email:    event.UserEmail,
+		}, event.OldUsername)
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "System Hook",
+		Action:    eventName,
+		Author:    event.UserUsername,
+		Title:     event.UserName,
+	}, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "User system event processed",
@@ -643,6 +720,13 @@ func handleGroupSystemEvent(e *core.RequestEvent, body []byte, eventName string)
 		}
 	}
 
+	dispatchNotification(e, notificationEvent{
+		EventType: "System Hook",
+		Action:    eventName,
+		Namespace: event.PathWithNamespace,
+		Title:     event.Name,
+	}, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Group system event processed",
@@ -705,6 +789,15 @@ func handleAccessRequestEvent(e *core.RequestEvent, body []byte, eventName strin
 		}
 	}
 
+	dispatchNotification(e, notificationEvent{
+		EventType: "System Hook",
+		Action:    eventName,
+		ProjectID: event.ProjectID,
+		Namespace: event.ProjectPath,
+		Author:    event.UserUsername,
+		Title:     event.UserName,
+	}, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Access request event processed",
@@ -753,6 +846,12 @@ func handleKeyEvent(e *core.RequestEvent, body []byte, eventName string) error {
 		}
 	}
 
+	dispatchNotification(e, notificationEvent{
+		EventType: "System Hook",
+		Action:    eventName,
+		Title:     event.UserName,
+	}, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Key event processed",
@@ -814,6 +913,14 @@ func handleRepositoryUpdateEvent(e *core.RequestEvent, body []byte) error {
 		}
 	}
 
+	dispatchNotification(e, notificationEvent{
+		EventType: "System Hook",
+		Action:    event.EventName,
+		ProjectID: event.ProjectID,
+		Namespace: event.Project.PathWithNamespace,
+		Title:     event.Project.Name,
+	}, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Repository update event processed",
@@ -881,6 +988,11 @@ func handleMemberApprovalEvent(e *core.RequestEvent, body []byte, baseEvent Syst
 		}
 	}
 
+	dispatchNotification(e, notificationEvent{
+		EventType: "System Hook",
+		Action:    event.Action,
+	}, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Member approval event processed",
@@ -918,74 +1030,99 @@ func handleMergeRequestEvent(e *core.RequestEvent, body []byte) error {
 	// 例如：
 	// 1. 保存MR信息到数据库
 	// 2. 触发自动化流程
-	// 3. 发送通知到飞书
+	// 3. 发送通知到飞书（见 notifyMergeRequestCard）
 	// 4. 执行代码质量检查
 
-	// 示例：保存MR事件到数据库
-	collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
-	if err != nil {
-		// 如果表不存在，先创建（这里简化处理，实际应该通过迁移创建）
-		app.Logger().Warn("gitlab_merge_requests collection not found", "error", err)
+	mr := mergeRequestState{
+		ID:             event.ObjectAttributes.ID,
+		IID:            event.ObjectAttributes.IID,
+		Title:          event.ObjectAttributes.Title,
+		Description:    event.ObjectAttributes.Description,
+		State:          event.ObjectAttributes.State,
+		Action:         event.ObjectAttributes.Action,
+		ProjectID:      event.Project.ID,
+		ProjectName:    event.Project.Name,
+		SourceBranch:   event.ObjectAttributes.SourceBranch,
+		TargetBranch:   event.ObjectAttributes.TargetBranch,
+		URL:            event.ObjectAttributes.URL,
+		CreatedAt:      event.ObjectAttributes.CreatedAt.Time,
+		UpdatedAt:      event.ObjectAttributes.UpdatedAt.Time,
+		MergeCommitSHA: event.ObjectAttributes.MergeCommitSHA,
+		EventSource:    "project_hook",
+	}
+	mr.BlockingDiscussionsResolved = event.ObjectAttributes.BlockingDiscussionsResolved
+
+	// 安全设置author信息，处理空值情况
+	if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Name != "" {
+		mr.AuthorName = event.ObjectAttributes.Author.Name
+	} else if event.User.ID > 0 && event.User.Name != "" {
+		// 使用事件触发用户作为备用author
+		app.Logger().Info("Using event user as author fallback",
+			"eventUserID", event.User.ID,
+			"eventUserName", event.User.Name,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorName = event.User.Name
 	} else {
-		record := core.NewRecord(collection)
-		record.Set("mr_id", event.ObjectAttributes.ID)
-		record.Set("mr_iid", event.ObjectAttributes.IID)
-		record.Set("title", event.ObjectAttributes.Title)
-		record.Set("description", event.ObjectAttributes.Description)
-		record.Set("state", event.ObjectAttributes.State)
-		record.Set("action", event.ObjectAttributes.Action)
+		app.Logger().Warn("Both author and event user are invalid, using default",
+			"authorID", event.ObjectAttributes.Author.ID,
+			"authorName", event.ObjectAttributes.Author.Name,
+			"eventUserID", event.User.ID,
+			"eventUserName", event.User.Name,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorName = "Unknown Author"
+	}
 
-		// 安全设置author信息，处理空值情况
-		if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Name != "" {
-			record.Set("author_name", event.ObjectAttributes.Author.Name)
-		} else if event.User.ID > 0 && event.User.Name != "" {
-			// 使用事件触发用户作为备用author
-			app.Logger().Info("Using event user as author fallback",
-				"eventUserID", event.User.ID,
-				"eventUserName", event.User.Name,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_name", event.User.Name)
-		} else {
-			app.Logger().Warn("Both author and event user are invalid, using default",
-				"authorID", event.ObjectAttributes.Author.ID,
-				"authorName", event.ObjectAttributes.Author.Name,
-				"eventUserID", event.User.ID,
-				"eventUserName", event.User.Name,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_name", "Unknown Author")
-		}
+	if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Username != "" {
+		mr.AuthorUsername = event.ObjectAttributes.Author.Username
+	} else if event.User.ID > 0 && event.User.Username != "" {
+		// 使用事件触发用户作为备用author
+		app.Logger().Info("Using event user username as author fallback",
+			"eventUserID", event.User.ID,
+			"eventUserUsername", event.User.Username,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorUsername = event.User.Username
+	} else {
+		app.Logger().Warn("Both author and event user username are invalid, using default",
+			"authorID", event.ObjectAttributes.Author.ID,
+			"authorUsername", event.ObjectAttributes.Author.Username,
+			"eventUserID", event.User.ID,
+			"eventUserUsername", event.User.Username,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorUsername = "unknown"
+	}
 
-		if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Username != "" {
-			record.Set("author_username", event.ObjectAttributes.Author.Username)
-		} else if event.User.ID > 0 && event.User.Username != "" {
-			// 使用事件触发用户作为备用author
-			app.Logger().Info("Using event user username as author fallback",
-				"eventUserID", event.User.ID,
-				"eventUserUsername", event.User.Username,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_username", event.User.Username)
-		} else {
-			app.Logger().Warn("Both author and event user username are invalid, using default",
-				"authorID", event.ObjectAttributes.Author.ID,
-				"authorUsername", event.ObjectAttributes.Author.Username,
-				"eventUserID", event.User.ID,
-				"eventUserUsername", event.User.Username,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_username", "unknown")
+	// 更新MR当前状态并记录事件历史，只有实际应用了新状态的事件才触发同步和通知，
+	// 避免乱序的旧事件或项目webhook与System Hook重复投递的同一事件重复通知
+	record, applied, err := upsertMergeRequest(app, mr, body)
+	if err != nil {
+		app.Logger().Error("Failed to save merge request record", "error", err)
+	} else if applied {
+		app.Logger().Info("Merge request record saved", "recordID", record.Id)
+
+		if client, ok := larkClientFromRequest(e); ok {
+			// 同步到映射的飞书多维表格，并在项目通知群中发送或更新MR卡片
+			syncRecordToLark(app, client, record, larkMappingSourceMergeRequest)
+			notifyMergeRequestCard(app, client, record)
+
+			// 私信通知新增的评审人和指派人
+			notifyMergeRequestParticipants(app, client, event.User, mr,
+				addedUsers(event.Changes.Reviewers.Previous, event.Changes.Reviewers.Current),
+				addedUsers(event.Changes.Assignees.Previous, event.Changes.Assignees.Current),
+			)
 		}
 
-		record.Set("project_id", event.Project.ID)
-		record.Set("project_name", event.Project.Name)
-		record.Set("source_branch", event.ObjectAttributes.SourceBranch)
-		record.Set("target_branch", event.ObjectAttributes.TargetBranch)
-		record.Set("url", event.ObjectAttributes.URL)
-		record.Set("event_data", string(body))
-
-		if err := app.Save(record); err != nil {
-			app.Logger().Error("Failed to save merge request record", "error", err)
-		} else {
-			app.Logger().Info("Merge request record saved", "recordID", record.Id)
-		}
+		dispatchNotification(e, notificationEvent{
+			EventType: "Merge Request Hook",
+			Action:    event.ObjectAttributes.Action,
+			ProjectID: event.Project.ID,
+			Namespace: event.Project.PathWithNamespace,
+			Branch:    event.ObjectAttributes.TargetBranch,
+			Labels:    labelTitles(event.Labels),
+			Author:    mr.AuthorUsername,
+			Actor:     event.User.Username,
+			Title:     event.ObjectAttributes.Title,
+			URL:       event.ObjectAttributes.URL,
+		}, body)
 	}
 
 	return e.JSON(http.StatusOK, map[string]interface{}{
@@ -1022,73 +1159,97 @@ func handleSystemHookMergeRequestEvent(e *core.RequestEvent, body []byte) error
 		"projectName", event.Project.Name,
 	)
 
-	// 保存System Hook MR事件到数据库
-	collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
-	if err != nil {
-		app.Logger().Warn("gitlab_merge_requests collection not found", "error", err)
+	mr := mergeRequestState{
+		ID:             event.ObjectAttributes.ID,
+		IID:            event.ObjectAttributes.IID,
+		Title:          event.ObjectAttributes.Title,
+		Description:    event.ObjectAttributes.Description,
+		State:          event.ObjectAttributes.State,
+		Action:         event.ObjectAttributes.Action,
+		ProjectID:      event.Project.ID,
+		ProjectName:    event.Project.Name,
+		SourceBranch:   event.ObjectAttributes.SourceBranch,
+		TargetBranch:   event.ObjectAttributes.TargetBranch,
+		URL:            event.ObjectAttributes.URL,
+		CreatedAt:      event.ObjectAttributes.CreatedAt.Time,
+		UpdatedAt:      event.ObjectAttributes.UpdatedAt.Time,
+		MergeCommitSHA: event.ObjectAttributes.MergeCommitSHA,
+		EventSource:    "system_hook", // 标记事件来源
+	}
+	mr.BlockingDiscussionsResolved = event.ObjectAttributes.BlockingDiscussionsResolved
+
+	// 安全设置author信息，处理空值情况
+	if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Name != "" {
+		mr.AuthorName = event.ObjectAttributes.Author.Name
+	} else if event.User.ID > 0 && event.User.Name != "" {
+		// 使用事件触发用户作为备用author
+		app.Logger().Info("Using event user as author fallback in system hook",
+			"eventUserID", event.User.ID,
+			"eventUserName", event.User.Name,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorName = event.User.Name
 	} else {
-		record := core.NewRecord(collection)
-		record.Set("mr_id", event.ObjectAttributes.ID)
-		record.Set("mr_iid", event.ObjectAttributes.IID)
-		record.Set("title", event.ObjectAttributes.Title)
-		record.Set("description", event.ObjectAttributes.Description)
-		record.Set("state", event.ObjectAttributes.State)
-		record.Set("action", event.ObjectAttributes.Action)
+		app.Logger().Warn("Both author and event user are invalid in system hook, using default",
+			"authorID", event.ObjectAttributes.Author.ID,
+			"authorName", event.ObjectAttributes.Author.Name,
+			"eventUserID", event.User.ID,
+			"eventUserName", event.User.Name,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorName = "Unknown Author"
+	}
 
-		// 安全设置author信息，处理空值情况
-		if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Name != "" {
-			record.Set("author_name", event.ObjectAttributes.Author.Name)
-		} else if event.User.ID > 0 && event.User.Name != "" {
-			// 使用事件触发用户作为备用author
-			app.Logger().Info("Using event user as author fallback in system hook",
-				"eventUserID", event.User.ID,
-				"eventUserName", event.User.Name,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_name", event.User.Name)
-		} else {
-			app.Logger().Warn("Both author and event user are invalid in system hook, using default",
-				"authorID", event.ObjectAttributes.Author.ID,
-				"authorName", event.ObjectAttributes.Author.Name,
-				"eventUserID", event.User.ID,
-				"eventUserName", event.User.Name,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_name", "Unknown Author")
-		}
+	if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Username != "" {
+		mr.AuthorUsername = event.ObjectAttributes.Author.Username
+	} else if event.User.ID > 0 && event.User.Username != "" {
+		// 使用事件触发用户作为备用author
+		app.Logger().Info("Using event user username as author fallback in system hook",
+			"eventUserID", event.User.ID,
+			"eventUserUsername", event.User.Username,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorUsername = event.User.Username
+	} else {
+		app.Logger().Warn("Both author and event user username are invalid in system hook, using default",
+			"authorID", event.ObjectAttributes.Author.ID,
+			"authorUsername", event.ObjectAttributes.Author.Username,
+			"eventUserID", event.User.ID,
+			"eventUserUsername", event.User.Username,
+			"mrID", event.ObjectAttributes.IID)
+		mr.AuthorUsername = "unknown"
+	}
 
-		if event.ObjectAttributes.Author.ID > 0 && event.ObjectAttributes.Author.Username != "" {
-			record.Set("author_username", event.ObjectAttributes.Author.Username)
-		} else if event.User.ID > 0 && event.User.Username != "" {
-			// 使用事件触发用户作为备用author
-			app.Logger().Info("Using event user username as author fallback in system hook",
-				"eventUserID", event.User.ID,
-				"eventUserUsername", event.User.Username,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_username", event.User.Username)
-		} else {
-			app.Logger().Warn("Both author and event user username are invalid in system hook, using default",
-				"authorID", event.ObjectAttributes.Author.ID,
-				"authorUsername", event.ObjectAttributes.Author.Username,
-				"eventUserID", event.User.ID,
-				"eventUserUsername", event.User.Username,
-				"mrID", event.ObjectAttributes.IID)
-			record.Set("author_username", "unknown")
+	// 更新MR当前状态并记录事件历史，只有实际应用了新状态的事件才触发同步和通知，
+	// 避免乱序的旧事件或项目webhook与System Hook重复投递的同一事件重复通知
+	record, applied, err := upsertMergeRequest(app, mr, body)
+	if err != nil {
+		app.Logger().Error("Failed to save system hook merge request record", "error", err)
+	} else if applied {
+		app.Logger().Info("System hook merge request record saved", "recordID", record.Id)
+
+		if client, ok := larkClientFromRequest(e); ok {
+			// 同步到映射的飞书多维表格，并在项目通知群中发送或更新MR卡片
+			syncRecordToLark(app, client, record, larkMappingSourceMergeRequest)
+			notifyMergeRequestCard(app, client, record)
+
+			// 私信通知新增的评审人和指派人
+			notifyMergeRequestParticipants(app, client, event.User, mr,
+				addedUsers(event.Changes.Reviewers.Previous, event.Changes.Reviewers.Current),
+				addedUsers(event.Changes.Assignees.Previous, event.Changes.Assignees.Current),
+			)
 		}
 
-		record.Set("project_id", event.Project.ID)
-		record.Set("project_name", event.Project.Name)
-		record.Set("source_branch", event.ObjectAttributes.SourceBranch)
-		record.Set("target_branch", event.ObjectAttributes.TargetBranch)
-		record.Set("url", event.ObjectAttributes.URL)
-		record.Set("created_at", event.ObjectAttributes.CreatedAt) // 保存原始时间字符串
-		record.Set("updated_at", event.ObjectAttributes.UpdatedAt) // 保存原始时间字符串
-		record.Set("event_source", "system_hook")                  // 标记事件来源
-		record.Set("event_data", string(body))
-
-		if err := app.Save(record); err != nil {
-			app.Logger().Error("Failed to save system hook merge request record", "error", err)
-		} else {
-			app.Logger().Info("System hook merge request record saved", "recordID", record.Id)
-		}
+		// 与项目webhook的MR事件使用同一事件类型匹配规则
+		dispatchNotification(e, notificationEvent{
+			EventType: "Merge Request Hook",
+			Action:    event.ObjectAttributes.Action,
+			ProjectID: event.Project.ID,
+			Namespace: event.Project.PathWithNamespace,
+			Branch:    event.ObjectAttributes.TargetBranch,
+			Labels:    labelTitles(event.Labels),
+			Author:    mr.AuthorUsername,
+			Actor:     event.User.Username,
+			Title:     event.ObjectAttributes.Title,
+			URL:       event.ObjectAttributes.URL,
+		}, body)
 	}
 
 	return e.JSON(http.StatusOK, map[string]interface{}{
@@ -1105,34 +1266,142 @@ func handleSystemHookMergeRequestEvent(e *core.RequestEvent, body []byte) error
 	})
 }
 
-// handlePushEvent 处理Push事件（占位符）
-func handlePushEvent(e *core.RequestEvent, body []byte) error {
-	e.App.Logger().Info("Push event received")
-	// TODO: 实现Push事件处理逻辑
-	return e.JSON(http.StatusOK, map[string]interface{}{
-		"status":  "success",
-		"message": "Push event received",
-	})
+// mergeRequestState MR的当前状态，由项目webhook和System Hook事件共同使用
+type mergeRequestState struct {
+	ID             int
+	IID            int
+	Title          string
+	Description    string
+	State          string
+	Action         string
+	AuthorName     string
+	AuthorUsername string
+	ProjectID      int
+	ProjectName    string
+	SourceBranch   string
+	TargetBranch   string
+	URL            string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	MergeCommitSHA string
+	EventSource    string
+
+	// BlockingDiscussionsResolved 为 nil 表示事件未携带该字段，保留记录中已有的阻塞讨论状态
+	BlockingDiscussionsResolved *bool
 }
 
-// handleTagPushEvent 处理Tag Push事件（占位符）
-func handleTagPushEvent(e *core.RequestEvent, body []byte) error {
-	e.App.Logger().Info("Tag push event received")
-	// TODO: 实现Tag Push事件处理逻辑
-	return e.JSON(http.StatusOK, map[string]interface{}{
-		"status":  "success",
-		"message": "Tag push event received",
-	})
+// upsertMergeRequest 按 (project_id, mr_iid) 更新或创建MR当前状态记录，并追加一条事件历史；
+// 返回的 applied 表示事件中的状态是否写入了当前状态（乱序的旧事件和重复投递的事件为 false）
+func upsertMergeRequest(app core.App, mr mergeRequestState, body []byte) (*core.Record, bool, error) {
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_merge_requests",
+		"project_id = {:projectID} && mr_iid = {:iid}",
+		dbx.Params{"projectID": mr.ProjectID, "iid": mr.IID},
+	)
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("gitlab_merge_requests")
+		if err != nil {
+			return nil, false, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("mr_iid", mr.IID)
+		record.Set("project_id", mr.ProjectID)
+	}
+
+	storedUpdatedAt := record.GetDateTime("updated_at").Time()
+
+	// 乱序到达的旧事件只记录历史，不覆盖当前状态；
+	// 更新时间和操作都与当前状态相同的事件视为同一事件的重复投递（例如同时配置了项目webhook和System Hook）
+	stale := !mr.UpdatedAt.IsZero() && mr.UpdatedAt.Before(storedUpdatedAt)
+	duplicate := !record.IsNew() && !mr.UpdatedAt.IsZero() && mr.UpdatedAt.Equal(storedUpdatedAt) &&
+		mr.Action == record.GetString("action")
+
+	applied := false
+	switch {
+	case stale:
+		app.Logger().Info("Skipping stale merge request state update",
+			"projectID", mr.ProjectID,
+			"mrIID", mr.IID,
+			"eventUpdatedAt", mr.UpdatedAt,
+			"storedUpdatedAt", record.GetDateTime("updated_at").String(),
+		)
+	case duplicate:
+		app.Logger().Info("Skipping duplicate merge request event",
+			"projectID", mr.ProjectID,
+			"mrIID", mr.IID,
+			"action", mr.Action,
+			"eventSource", mr.EventSource,
+			"storedEventSource", record.GetString("event_source"),
+		)
+	default:
+		if err := saveMergeRequestState(app, record, mr, body); err != nil {
+			return nil, false, err
+		}
+		applied = true
+	}
+
+	// 记录事件历史
+	eventsCollection, err := app.FindCollectionByNameOrId("gitlab_merge_request_events")
+	if err != nil {
+		app.Logger().Warn("gitlab_merge_request_events collection not found", "error", err)
+		return record, applied, nil
+	}
+
+	event := core.NewRecord(eventsCollection)
+	event.Set("merge_request", record.Id)
+	event.Set("mr_id", mr.ID)
+	event.Set("mr_iid", mr.IID)
+	event.Set("project_id", mr.ProjectID)
+	event.Set("action", mr.Action)
+	event.Set("state", mr.State)
+	event.Set("event_source", mr.EventSource)
+	event.Set("received_at", types.NowDateTime())
+	event.Set("event_data", string(body))
+
+	if err := app.Save(event); err != nil {
+		app.Logger().Error("Failed to save merge request event history", "error", err, "recordID", record.Id)
+	}
+
+	return record, applied, nil
 }
 
-// handleIssuesEvent 处理Issues事件（占位符）
-func handleIssuesEvent(e *core.RequestEvent, body []byte) error {
-	e.App.Logger().Info("Issues event received")
-	// TODO: 实现Issues事件处理逻辑
-	return e.JSON(http.StatusOK, map[string]interface{}{
-		"status":  "success",
-		"message": "Issues event received",
-	})
+// saveMergeRequestState 将MR状态写入当前状态记录
+func saveMergeRequestState(app core.App, record *core.Record, mr mergeRequestState, body []byte) error {
+	record.Set("mr_id", mr.ID)
+	record.Set("title", mr.Title)
+	record.Set("description", mr.Description)
+	record.Set("state", mr.State)
+	record.Set("action", mr.Action)
+	record.Set("author_name", mr.AuthorName)
+	record.Set("author_username", mr.AuthorUsername)
+	record.Set("project_name", mr.ProjectName)
+	record.Set("source_branch", mr.SourceBranch)
+	record.Set("target_branch", mr.TargetBranch)
+	record.Set("url", mr.URL)
+	if !mr.CreatedAt.IsZero() {
+		record.Set("created_at", mr.CreatedAt)
+	}
+	if !mr.UpdatedAt.IsZero() {
+		record.Set("updated_at", mr.UpdatedAt)
+	}
+	if mr.MergeCommitSHA != "" {
+		record.Set("merge_commit_sha", mr.MergeCommitSHA)
+	}
+	// 首次进入合并状态时记录合并时间
+	if mr.State == "merged" && record.GetDateTime("merged_at").IsZero() {
+		mergedAt := mr.UpdatedAt
+		if mergedAt.IsZero() {
+			mergedAt = time.Now()
+		}
+		record.Set("merged_at", mergedAt)
+	}
+	if mr.BlockingDiscussionsResolved != nil {
+		record.Set("has_blocking_discussions", !*mr.BlockingDiscussionsResolved)
+	}
+	record.Set("event_source", mr.EventSource)
+	record.Set("event_data", string(body))
+
+	return app.Save(record)
 }
 
 // handleNoteEvent 处理Note事件（评论事件）
@@ -1229,6 +1498,11 @@ func handleNoteEvent(e *core.RequestEvent, body []byte) error {
 				record.Set("noteable_id", event.Issue.IID)
 				record.Set("noteable_title", event.Issue.Title)
 				record.Set("noteable_state", event.Issue.State)
+
+				// 关联Issue当前状态记录，Issue尚未入库时由Issue事件补上关联
+				if issue, err := findIssueRecord(app, event.Project.ID, event.Issue.IID); err == nil {
+					record.Set("issue", issue.Id)
+				}
 			}
 		case "Commit":
 			if event.Commit != nil {
@@ -1260,6 +1534,29 @@ func handleNoteEvent(e *core.RequestEvent, body []byte) error {
 		}
 	}
 
+	// 评论可能新增或解决阻塞讨论，更新MR状态和飞书卡片
+	if event.ObjectAttributes.NoteableType == "MergeRequest" && event.MergeRequest != nil {
+		updateMergeRequestBlocking(e, event.Project.ID, event.MergeRequest)
+	}
+
+	notification := notificationEvent{
+		EventType: "Note Hook",
+		Action:    event.ObjectAttributes.Action,
+		ProjectID: event.Project.ID,
+		Namespace: event.Project.PathWithNamespace,
+		Author:    event.User.Username,
+		Title:     event.ObjectAttributes.Note,
+		URL:       event.ObjectAttributes.URL,
+	}
+	switch {
+	case event.MergeRequest != nil:
+		notification.Branch = event.MergeRequest.TargetBranch
+		notification.Labels = labelTitles(event.MergeRequest.Labels)
+	case event.Issue != nil:
+		notification.Labels = labelTitles(event.Issue.Labels)
+	}
+	dispatchNotification(e, notification, body)
+
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Note event processed",
@@ -1345,6 +1642,8 @@ type MergeRequest struct {
 	Draft               bool    `json:"draft"`
 	Assignee            User    `json:"assignee"`
 	DetailedMergeStatus string  `json:"detailed_merge_status"`
+
+	BlockingDiscussionsResolved *bool `json:"blocking_discussions_resolved"` // 较旧的GitLab版本不包含该字段
 }
 
 // Issue Issue信息
@@ -0,0 +1,310 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// GitLabWikiPageEvent GitLab Wiki Page Hook事件数据结构
+type GitLabWikiPageEvent struct {
+	ObjectKind       string             `json:"object_kind"`
+	User             User               `json:"user"`
+	Project          Project            `json:"project"`
+	ObjectAttributes WikiPageAttributes `json:"object_attributes"`
+}
+
+// WikiPageAttributes Wiki页面属性
+type WikiPageAttributes struct {
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	Format    string `json:"format"`
+	Message   string `json:"message"`
+	Slug      string `json:"slug"`
+	URL       string `json:"url"`
+	Action    string `json:"action"`
+	DiffURL   string `json:"diff_url"`
+	VersionID string `json:"version_id"`
+}
+
+// GitLabFeatureFlagEvent GitLab Feature Flag Hook事件数据结构
+type GitLabFeatureFlagEvent struct {
+	ObjectKind       string                `json:"object_kind"`
+	User             User                  `json:"user"`
+	Project          Project               `json:"project"`
+	ObjectAttributes FeatureFlagAttributes `json:"object_attributes"`
+}
+
+// FeatureFlagAttributes 功能开关属性
+type FeatureFlagAttributes struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Active      bool   `json:"active"`
+}
+
+// GitLabEmojiEvent GitLab Emoji Hook事件数据结构
+type GitLabEmojiEvent struct {
+	ObjectKind       string          `json:"object_kind"`
+	EventType        string          `json:"event_type"`
+	User             User            `json:"user"`
+	ProjectID        int             `json:"project_id"`
+	Project          Project         `json:"project"`
+	ObjectAttributes EmojiAttributes `json:"object_attributes"`
+	MergeRequest     *MergeRequest   `json:"merge_request,omitempty"` // 回应目标为MR或MR评论时
+	Note             *EmojiNote      `json:"note,omitempty"`          // 回应目标为评论时
+}
+
+// EmojiAttributes 表情回应属性
+type EmojiAttributes struct {
+	ID            int          `json:"id"`
+	Name          string       `json:"name"`
+	UserID        int          `json:"user_id"`
+	AwardableType string       `json:"awardable_type"`
+	AwardableID   int          `json:"awardable_id"`
+	CreatedAt     FlexibleTime `json:"created_at"`
+	UpdatedAt     FlexibleTime `json:"updated_at"`
+}
+
+// EmojiNote 表情回应目标评论
+type EmojiNote struct {
+	ID           int    `json:"id"`
+	NoteableType string `json:"noteable_type"`
+	NoteableID   int    `json:"noteable_id"`
+}
+
+// handleWikiPageEvent 处理Wiki Page事件
+func handleWikiPageEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabWikiPageEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse wiki page event", "error", err)
+		return e.BadRequestError("Invalid wiki page event format", err)
+	}
+
+	attrs := event.ObjectAttributes
+
+	app.Logger().Info("Processing wiki page event",
+		"action", attrs.Action,
+		"slug", attrs.Slug,
+		"title", attrs.Title,
+		"user", event.User.Username,
+		"projectName", event.Project.Name,
+	)
+
+	// 保存Wiki页面事件到数据库
+	collection, err := app.FindCollectionByNameOrId("gitlab_wiki_page_events")
+	if err != nil {
+		app.Logger().Warn("gitlab_wiki_page_events collection not found", "error", err)
+	} else {
+		record := core.NewRecord(collection)
+		record.Set("project_id", event.Project.ID)
+		record.Set("project_name", event.Project.Name)
+		record.Set("action", attrs.Action)
+		record.Set("title", attrs.Title)
+		record.Set("slug", attrs.Slug)
+		record.Set("format", attrs.Format)
+		record.Set("message", attrs.Message)
+		record.Set("version_id", attrs.VersionID)
+		record.Set("url", attrs.URL)
+		record.Set("diff_url", attrs.DiffURL)
+		record.Set("user_id", event.User.ID)
+		record.Set("user_username", event.User.Username)
+		record.Set("event_data", string(body))
+
+		if err := app.Save(record); err != nil {
+			app.Logger().Error("Failed to save wiki page event record", "error", err)
+		} else {
+			app.Logger().Info("Wiki page event record saved", "recordID", record.Id)
+		}
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Wiki Page Hook",
+		Action:    attrs.Action,
+		ProjectID: event.Project.ID,
+		Namespace: event.Project.PathWithNamespace,
+		Author:    event.User.Username,
+		Title:     attrs.Title,
+		URL:       attrs.URL,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Wiki page event processed",
+		"event": map[string]interface{}{
+			"action":  attrs.Action,
+			"slug":    attrs.Slug,
+			"project": event.Project.Name,
+		},
+	})
+}
+
+// handleFeatureFlagEvent 处理Feature Flag事件
+func handleFeatureFlagEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabFeatureFlagEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse feature flag event", "error", err)
+		return e.BadRequestError("Invalid feature flag event format", err)
+	}
+
+	attrs := event.ObjectAttributes
+
+	app.Logger().Info("Processing feature flag event",
+		"flagID", attrs.ID,
+		"name", attrs.Name,
+		"active", attrs.Active,
+		"user", event.User.Username,
+		"projectName", event.Project.Name,
+	)
+
+	// 保存功能开关事件到数据库
+	collection, err := app.FindCollectionByNameOrId("gitlab_feature_flag_events")
+	if err != nil {
+		app.Logger().Warn("gitlab_feature_flag_events collection not found", "error", err)
+	} else {
+		record := core.NewRecord(collection)
+		record.Set("project_id", event.Project.ID)
+		record.Set("project_name", event.Project.Name)
+		record.Set("flag_id", attrs.ID)
+		record.Set("name", attrs.Name)
+		record.Set("description", attrs.Description)
+		record.Set("active", attrs.Active)
+		record.Set("user_id", event.User.ID)
+		record.Set("user_username", event.User.Username)
+		record.Set("event_data", string(body))
+
+		if err := app.Save(record); err != nil {
+			app.Logger().Error("Failed to save feature flag event record", "error", err)
+		} else {
+			app.Logger().Info("Feature flag event record saved", "recordID", record.Id)
+		}
+	}
+
+	// Feature Flag Hook 没有 action 字段，按开关状态生成
+	action := "deactivated"
+	if attrs.Active {
+		action = "activated"
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Feature Flag Hook",
+		Action:    action,
+		ProjectID: event.Project.ID,
+		Namespace: event.Project.PathWithNamespace,
+		Author:    event.User.Username,
+		Title:     attrs.Name,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Feature flag event processed",
+		"event": map[string]interface{}{
+			"flag_id": attrs.ID,
+			"name":    attrs.Name,
+			"active":  attrs.Active,
+			"project": event.Project.Name,
+		},
+	})
+}
+
+// handleEmojiEvent 处理Emoji事件
+func handleEmojiEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabEmojiEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse emoji event", "error", err)
+		return e.BadRequestError("Invalid emoji event format", err)
+	}
+
+	attrs := event.ObjectAttributes
+
+	projectID := event.Project.ID
+	if projectID == 0 {
+		projectID = event.ProjectID
+	}
+
+	app.Logger().Info("Processing emoji event",
+		"eventType", event.EventType,
+		"name", attrs.Name,
+		"awardableType", attrs.AwardableType,
+		"awardableID", attrs.AwardableID,
+		"user", event.User.Username,
+		"projectName", event.Project.Name,
+	)
+
+	// 保存表情回应事件到数据库
+	collection, err := app.FindCollectionByNameOrId("gitlab_emoji_events")
+	if err != nil {
+		app.Logger().Warn("gitlab_emoji_events collection not found", "error", err)
+	} else {
+		record := core.NewRecord(collection)
+		record.Set("project_id", projectID)
+		record.Set("project_name", event.Project.Name)
+		record.Set("event_type", event.EventType)
+		record.Set("award_id", attrs.ID)
+		record.Set("name", attrs.Name)
+		record.Set("awardable_type", attrs.AwardableType)
+		record.Set("awardable_id", attrs.AwardableID)
+		record.Set("user_id", event.User.ID)
+		record.Set("user_username", event.User.Username)
+		if !attrs.CreatedAt.IsZero() {
+			record.Set("awarded_at", attrs.CreatedAt.Time)
+		}
+		if event.Note != nil {
+			record.Set("note_id", event.Note.ID)
+		}
+
+		// 关联MR当前状态记录
+		if event.MergeRequest != nil {
+			mrProjectID := event.MergeRequest.TargetProjectID
+			if mrProjectID == 0 {
+				mrProjectID = projectID
+			}
+			record.Set("mr_iid", event.MergeRequest.IID)
+
+			mr, err := app.FindFirstRecordByFilter(
+				"gitlab_merge_requests",
+				"project_id = {:projectID} && mr_iid = {:iid}",
+				dbx.Params{"projectID": mrProjectID, "iid": event.MergeRequest.IID},
+			)
+			if err == nil {
+				record.Set("merge_request", mr.Id)
+			}
+		}
+
+		record.Set("event_data", string(body))
+
+		if err := app.Save(record); err != nil {
+			app.Logger().Error("Failed to save emoji event record", "error", err)
+		} else {
+			app.Logger().Info("Emoji event record saved", "recordID", record.Id)
+		}
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Emoji Hook",
+		Action:    event.EventType,
+		ProjectID: projectID,
+		Namespace: event.Project.PathWithNamespace,
+		Author:    event.User.Username,
+		Title:     attrs.Name,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Emoji event processed",
+		"event": map[string]interface{}{
+			"event_type":     event.EventType,
+			"name":           attrs.Name,
+			"awardable_type": attrs.AwardableType,
+			"project":        event.Project.Name,
+		},
+	})
+}
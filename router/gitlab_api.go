@@ -0,0 +1,107 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"gitlab.yogorobot.com/sre/lark-base-mapping/middlewares"
+)
+
+// gitlabAPITimeout GitLab API 请求超时时间
+const gitlabAPITimeout = 10 * time.Second
+
+// gitlabUsersPerPage 分页查询GitLab用户时每页的数量
+const gitlabUsersPerPage = 100
+
+// gitlabAPIClient GitLab REST API v4 客户端，使用 GITLAB_API_TOKEN 认证
+type gitlabAPIClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// gitlabAPIClientFromRequest 从请求上下文中的GitLab配置创建API客户端，未配置 API token 时返回 false
+func gitlabAPIClientFromRequest(e *core.RequestEvent) (*gitlabAPIClient, bool) {
+	config, ok := middlewares.GetGitLabConfigFromContext(e.Request.Context())
+	if !ok || config.APIToken == "" {
+		return nil, false
+	}
+
+	return &gitlabAPIClient{
+		baseURL:    strings.TrimRight(config.BaseURL, "/"),
+		token:      config.APIToken,
+		httpClient: &http.Client{Timeout: gitlabAPITimeout},
+	}, true
+}
+
+// do 发送API请求，body 非 nil 时按JSON编码发送，result 非 nil 时解析响应
+func (c *gitlabAPIClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v4"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gitlab api %s %s failed, status: %d, body: %s", method, path, resp.StatusCode, message)
+	}
+
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
+
+// UpdateIssue 更新Issue，params 为 PUT /projects/:id/issues/:iid 的参数（state_event、labels、assignee_ids 等）
+func (c *gitlabAPIClient) UpdateIssue(ctx context.Context, projectID, iid int, params map[string]interface{}) error {
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("/projects/%d/issues/%d", projectID, iid), params, nil)
+}
+
+// FindUserID 按用户名查询GitLab用户ID
+func (c *gitlabAPIClient) FindUserID(ctx context.Context, username string) (int, error) {
+	var users []User
+	if err := c.do(ctx, http.MethodGet, "/users?username="+url.QueryEscape(username), nil, &users); err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, fmt.Errorf("gitlab user %q not found", username)
+	}
+	return users[0].ID, nil
+}
+
+// ListUsers 分页查询活跃的GitLab用户，page 从 1 开始；管理员 token 才能读取用户邮箱
+func (c *gitlabAPIClient) ListUsers(ctx context.Context, page int) ([]User, error) {
+	var users []User
+	path := fmt.Sprintf("/users?active=true&per_page=%d&page=%d", gitlabUsersPerPage, page)
+	if err := c.do(ctx, http.MethodGet, path, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,259 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// GitLabDeploymentEvent GitLab Deployment Hook事件数据结构
+type GitLabDeploymentEvent struct {
+	ObjectKind             string       `json:"object_kind"`
+	Status                 string       `json:"status"`
+	StatusChangedAt        FlexibleTime `json:"status_changed_at"`
+	DeploymentID           int          `json:"deployment_id"`
+	DeployableID           int          `json:"deployable_id"`
+	DeployableURL          string       `json:"deployable_url"`
+	Environment            string       `json:"environment"`
+	EnvironmentTier        string       `json:"environment_tier"`
+	EnvironmentSlug        string       `json:"environment_slug"`
+	EnvironmentExternalURL string       `json:"environment_external_url"`
+	Project                Project      `json:"project"`
+	ShortSHA               string       `json:"short_sha"`
+	User                   User         `json:"user"`
+	UserURL                string       `json:"user_url"`
+	CommitURL              string       `json:"commit_url"`
+	CommitTitle            string       `json:"commit_title"`
+	Ref                    string       `json:"ref"`
+}
+
+// GitLabReleaseEvent GitLab Release Hook事件数据结构
+type GitLabReleaseEvent struct {
+	ObjectKind  string       `json:"object_kind"`
+	ID          int          `json:"id"`
+	CreatedAt   FlexibleTime `json:"created_at"`
+	Description string       `json:"description"`
+	Name        string       `json:"name"`
+	ReleasedAt  FlexibleTime `json:"released_at"`
+	Tag         string       `json:"tag"`
+	Project     Project      `json:"project"`
+	URL         string       `json:"url"`
+	Action      string       `json:"action"`
+	Commit      Commit       `json:"commit"`
+}
+
+// deploymentFinishedStatuses 部署的终态
+var deploymentFinishedStatuses = map[string]bool{
+	"success":  true,
+	"failed":   true,
+	"canceled": true,
+}
+
+// handleDeploymentEvent 处理Deployment事件
+func handleDeploymentEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabDeploymentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse deployment event", "error", err)
+		return e.BadRequestError("Invalid deployment event format", err)
+	}
+
+	app.Logger().Info("Processing deployment event",
+		"deploymentID", event.DeploymentID,
+		"status", event.Status,
+		"environment", event.Environment,
+		"ref", event.Ref,
+		"shortSHA", event.ShortSHA,
+		"projectName", event.Project.Name,
+	)
+
+	if record, err := upsertDeployment(app, event, body); err != nil {
+		app.Logger().Error("Failed to save deployment record", "error", err)
+	} else {
+		app.Logger().Info("Deployment record saved", "recordID", record.Id)
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Deployment Hook",
+		Action:    event.Status,
+		ProjectID: event.Project.ID,
+		Namespace: event.Project.PathWithNamespace,
+		Branch:    event.Ref,
+		Author:    event.User.Username,
+		Title:     event.Environment,
+		URL:       event.DeployableURL,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Deployment event processed",
+		"event": map[string]interface{}{
+			"deployment_id": event.DeploymentID,
+			"status":        event.Status,
+			"environment":   event.Environment,
+			"project":       event.Project.Name,
+		},
+	})
+}
+
+// upsertDeployment 按 deployment_id 更新或创建部署记录
+func upsertDeployment(app core.App, event GitLabDeploymentEvent, body []byte) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_deployments",
+		"deployment_id = {:deploymentID}",
+		dbx.Params{"deploymentID": event.DeploymentID},
+	)
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("gitlab_deployments")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("deployment_id", event.DeploymentID)
+	}
+
+	changedAt := types.NowDateTime()
+	if !event.StatusChangedAt.IsZero() {
+		changedAt, _ = types.ParseDateTime(event.StatusChangedAt.Time)
+	}
+
+	// 乱序到达的旧状态不覆盖当前状态：事件时间早于已保存的状态，
+	// 或时间相同但已保存终态而事件不是终态（如迟到的 running）时忽略
+	if stored := record.GetDateTime("status_changed_at"); !stored.IsZero() &&
+		(changedAt.Before(stored) ||
+			changedAt.Equal(stored) && deploymentFinishedStatuses[record.GetString("status")] && !deploymentFinishedStatuses[event.Status]) {
+		app.Logger().Info("Skipping stale deployment status update",
+			"deploymentID", event.DeploymentID,
+			"eventStatus", event.Status,
+			"storedStatus", record.GetString("status"),
+		)
+		return record, nil
+	}
+
+	if record.IsNew() {
+		record.Set("created_at", changedAt)
+	}
+
+	record.Set("project_id", event.Project.ID)
+	record.Set("project_name", event.Project.Name)
+	record.Set("environment", event.Environment)
+	record.Set("environment_tier", event.EnvironmentTier)
+	record.Set("environment_slug", event.EnvironmentSlug)
+	record.Set("environment_external_url", event.EnvironmentExternalURL)
+	record.Set("status", event.Status)
+	record.Set("ref", event.Ref)
+	record.Set("short_sha", event.ShortSHA)
+	record.Set("commit_url", event.CommitURL)
+	record.Set("commit_title", event.CommitTitle)
+	record.Set("deployable_id", event.DeployableID)
+	record.Set("deployable_url", event.DeployableURL)
+	record.Set("user_id", event.User.ID)
+	record.Set("user_username", event.User.Username)
+	record.Set("status_changed_at", changedAt)
+	if deploymentFinishedStatuses[event.Status] {
+		record.Set("finished_at", changedAt)
+	} else {
+		record.Set("finished_at", "")
+	}
+	record.Set("event_data", string(body))
+	appendStatusHistory(record, event.Status)
+
+	if err := app.Save(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// handleReleaseEvent 处理Release事件
+func handleReleaseEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabReleaseEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse release event", "error", err)
+		return e.BadRequestError("Invalid release event format", err)
+	}
+
+	app.Logger().Info("Processing release event",
+		"releaseID", event.ID,
+		"action", event.Action,
+		"tag", event.Tag,
+		"name", event.Name,
+		"projectName", event.Project.Name,
+	)
+
+	if record, err := upsertRelease(app, event, body); err != nil {
+		app.Logger().Error("Failed to save release record", "error", err)
+	} else {
+		app.Logger().Info("Release record saved", "recordID", record.Id)
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Release Hook",
+		Action:    event.Action,
+		ProjectID: event.Project.ID,
+		Namespace: event.Project.PathWithNamespace,
+		Title:     event.Name,
+		URL:       event.URL,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Release event processed",
+		"event": map[string]interface{}{
+			"release_id": event.ID,
+			"action":     event.Action,
+			"tag":        event.Tag,
+			"project":    event.Project.Name,
+		},
+	})
+}
+
+// upsertRelease 按 release_id 更新或创建发布记录，删除事件只做软删除
+func upsertRelease(app core.App, event GitLabReleaseEvent, body []byte) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_releases",
+		"release_id = {:releaseID}",
+		dbx.Params{"releaseID": event.ID},
+	)
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("gitlab_releases")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("release_id", event.ID)
+	}
+
+	record.Set("project_id", event.Project.ID)
+	record.Set("project_name", event.Project.Name)
+	record.Set("tag", event.Tag)
+	record.Set("name", event.Name)
+	record.Set("description", event.Description)
+	record.Set("action", event.Action)
+	record.Set("commit_sha", event.Commit.ID)
+	record.Set("url", event.URL)
+	if !event.CreatedAt.IsZero() {
+		record.Set("created_at", event.CreatedAt.Time)
+	}
+	record.Set("released_at", dateOrEmpty(event.ReleasedAt))
+	if event.Action == "delete" {
+		record.Set("deleted", true)
+		record.Set("deleted_at", types.NowDateTime())
+	} else {
+		record.Set("deleted", false)
+		record.Set("deleted_at", "")
+	}
+	record.Set("event_data", string(body))
+
+	if err := app.Save(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
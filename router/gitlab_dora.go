@@ -0,0 +1,338 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// defaultDORAPeriod 未指定 from 时的统计区间长度
+const defaultDORAPeriod = 30 * 24 * time.Hour
+
+// GitLabDORAMetrics 计算项目在时间区间内的DORA指标
+//
+// 查询参数：from、to（日期或RFC3339时间，默认最近30天），
+// environment（默认统计 environment_tier 为 production 的环境）。
+// 指标基于 gitlab_deployments 中进入终态的部署：
+//   - 部署频率：成功部署次数及日均次数
+//   - 变更前置时间：MR合并到包含它的首次成功部署的时长，按部署的 ref 匹配MR目标分支，
+//     该 ref 没有更早的成功部署时只统计区间内合并的MR；ref 为标签时按标签指向提交的推送时间
+//     取上一次标签部署之后推送的提交，再按 merge_commit_sha 匹配MR；
+//     ref 既不是已记录的标签也不是任何MR的目标分支时计入 unresolved_deployments
+//   - 变更失败率：失败部署占成功和失败部署之和的比例
+//   - 服务恢复时间：连续失败中的首次失败到下一次成功部署的时长
+func GitLabDORAMetrics(e *core.RequestEvent) error {
+	app := e.App
+
+	projectID, err := strconv.Atoi(e.Request.PathValue("projectID"))
+	if err != nil {
+		return e.BadRequestError("Invalid project ID", err)
+	}
+
+	query := e.Request.URL.Query()
+
+	to := time.Now().UTC()
+	if v := query.Get("to"); v != "" {
+		if to, err = parseDateParam(v); err != nil {
+			return e.BadRequestError("Invalid to", err)
+		}
+		// 只给日期时包含当天
+		if len(v) == len("2006-01-02") {
+			to = to.Add(24 * time.Hour)
+		}
+	}
+
+	from := to.Add(-defaultDORAPeriod)
+	if v := query.Get("from"); v != "" {
+		if from, err = parseDateParam(v); err != nil {
+			return e.BadRequestError("Invalid from", err)
+		}
+	}
+
+	if !from.Before(to) {
+		return e.BadRequestError("from must be before to", nil)
+	}
+
+	environment := query.Get("environment")
+	envFilter := "environment_tier = 'production'"
+	if environment != "" {
+		envFilter = "environment = {:environment}"
+	}
+
+	params := dbx.Params{
+		"projectID":   projectID,
+		"environment": environment,
+		"from":        formatDateTime(from),
+		"to":          formatDateTime(to),
+	}
+
+	deployments, err := app.FindRecordsByFilter(
+		"gitlab_deployments",
+		"project_id = {:projectID} && "+envFilter+" && finished_at >= {:from} && finished_at <= {:to} && (status = 'success' || status = 'failed')",
+		"finished_at",
+		0,
+		0,
+		params,
+	)
+	if err != nil {
+		return e.InternalServerError("Failed to load deployments", err)
+	}
+
+	// 各 ref 上一次成功部署的时间，作为变更前置时间的起点
+	previousByRef := map[string]time.Time{}
+	// 上一次成功部署的标签指向提交的推送时间，作为标签部署的变更前置时间起点
+	var previousTagBoundary time.Time
+
+	var (
+		succeeded    int
+		failed       int
+		unresolved   int
+		leadTimes    []float64
+		restoreTimes []float64
+		failingSince time.Time
+	)
+
+	for _, deployment := range deployments {
+		finishedAt := deployment.GetDateTime("finished_at").Time()
+
+		if deployment.GetString("status") == "failed" {
+			failed++
+			if failingSince.IsZero() {
+				failingSince = finishedAt
+			}
+			continue
+		}
+
+		succeeded++
+		if !failingSince.IsZero() {
+			restoreTimes = append(restoreTimes, finishedAt.Sub(failingSince).Seconds())
+			failingSince = time.Time{}
+		}
+
+		ref := deployment.GetString("ref")
+
+		// 标签部署包含上一次标签部署之后推送、且在标签指向提交之前的提交对应的MR
+		if tag, err := findTag(app, projectID, ref); err == nil {
+			if previousTagBoundary.IsZero() {
+				previousTagBoundary = lastSuccessfulTagBoundary(app, projectID, envFilter, params, from)
+			}
+			boundary := tagBoundary(app, projectID, tag).Time()
+
+			mergeRequests, err := mergeRequestsPushedBetween(app, projectID, previousTagBoundary, boundary)
+			if err != nil {
+				return e.InternalServerError("Failed to load merge requests", err)
+			}
+			for _, mr := range mergeRequests {
+				leadTimes = append(leadTimes, finishedAt.Sub(mr.GetDateTime("merged_at").Time()).Seconds())
+			}
+
+			// 回滚到旧标签时不回退起点，避免下一次部署重复统计
+			if boundary.After(previousTagBoundary) {
+				previousTagBoundary = boundary
+			}
+			continue
+		}
+
+		if !isMergeRequestTargetBranch(app, projectID, ref) {
+			unresolved++
+			continue
+		}
+
+		// 本次部署包含上一次成功部署之后合并到该 ref 的MR
+		since, ok := previousByRef[ref]
+		if !ok {
+			since = lastSuccessfulDeployment(app, projectID, envFilter, params, ref, from)
+		}
+		previousByRef[ref] = finishedAt
+
+		mergeRequests, err := app.FindRecordsByFilter(
+			"gitlab_merge_requests",
+			"project_id = {:projectID} && target_branch = {:ref} && merged_at > {:since} && merged_at <= {:until}",
+			"",
+			0,
+			0,
+			dbx.Params{
+				"projectID": projectID,
+				"ref":       ref,
+				"since":     formatDateTime(since),
+				"until":     formatDateTime(finishedAt),
+			},
+		)
+		if err != nil {
+			return e.InternalServerError("Failed to load merge requests", err)
+		}
+
+		for _, mr := range mergeRequests {
+			leadTimes = append(leadTimes, finishedAt.Sub(mr.GetDateTime("merged_at").Time()).Seconds())
+		}
+	}
+
+	days := to.Sub(from).Hours() / 24
+
+	changeFailureRate := 0.0
+	if succeeded+failed > 0 {
+		changeFailureRate = float64(failed) / float64(succeeded+failed)
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":      "success",
+		"project_id":  projectID,
+		"environment": environment,
+		"from":        from.Format(time.RFC3339),
+		"to":          to.Format(time.RFC3339),
+		"deployment_frequency": map[string]interface{}{
+			"deployments": succeeded,
+			"days":        days,
+			"per_day":     float64(succeeded) / days,
+		},
+		"lead_time_for_changes": map[string]interface{}{
+			"merge_requests":         len(leadTimes),
+			"unresolved_deployments": unresolved,
+			"median_seconds":         median(leadTimes),
+			"mean_seconds":           mean(leadTimes),
+		},
+		"change_failure_rate": map[string]interface{}{
+			"deployments": succeeded + failed,
+			"failed":      failed,
+			"rate":        changeFailureRate,
+		},
+		"time_to_restore": map[string]interface{}{
+			"restores":       len(restoreTimes),
+			"unresolved":     !failingSince.IsZero(),
+			"median_seconds": median(restoreTimes),
+			"mean_seconds":   mean(restoreTimes),
+		},
+	})
+}
+
+// lastSuccessfulDeployment 返回区间开始前该 ref 最后一次成功部署的时间，没有时退回区间开始时间
+func lastSuccessfulDeployment(app core.App, projectID int, envFilter string, params dbx.Params, ref string, from time.Time) time.Time {
+	records, err := app.FindRecordsByFilter(
+		"gitlab_deployments",
+		"project_id = {:projectID} && "+envFilter+" && ref = {:ref} && finished_at < {:from} && status = 'success'",
+		"-finished_at",
+		1,
+		0,
+		params,
+		dbx.Params{"ref": ref},
+	)
+	if err != nil || len(records) == 0 {
+		return from
+	}
+	return records[0].GetDateTime("finished_at").Time()
+}
+
+// lastSuccessfulTagBoundary 返回区间开始前最后一次成功部署的标签指向提交的推送时间，
+// 该部署不是标签部署或没有更早的部署时退回区间开始时间
+func lastSuccessfulTagBoundary(app core.App, projectID int, envFilter string, params dbx.Params, from time.Time) time.Time {
+	records, err := app.FindRecordsByFilter(
+		"gitlab_deployments",
+		"project_id = {:projectID} && "+envFilter+" && finished_at < {:from} && status = 'success'",
+		"-finished_at",
+		1,
+		0,
+		params,
+	)
+	if err != nil || len(records) == 0 {
+		return from
+	}
+
+	tag, err := findTag(app, projectID, records[0].GetString("ref"))
+	if err != nil {
+		return from
+	}
+	return tagBoundary(app, projectID, tag).Time()
+}
+
+// mergeRequestsPushedBetween 返回合并提交在区间 (since, until] 内推送的MR
+func mergeRequestsPushedBetween(app core.App, projectID int, since, until time.Time) ([]*core.Record, error) {
+	if !since.Before(until) {
+		return nil, nil
+	}
+
+	commits, err := app.FindRecordsByFilter(
+		"gitlab_commits",
+		"project_id = {:projectID} && pushed_at > {:since} && pushed_at <= {:until}",
+		"",
+		0,
+		0,
+		dbx.Params{"projectID": projectID, "since": formatDateTime(since), "until": formatDateTime(until)},
+	)
+	if err != nil || len(commits) == 0 {
+		return nil, err
+	}
+
+	shas := make([]interface{}, 0, len(commits))
+	for _, commit := range commits {
+		shas = append(shas, commit.GetString("sha"))
+	}
+
+	var records []*core.Record
+	err = app.RecordQuery("gitlab_merge_requests").
+		AndWhere(dbx.HashExp{"project_id": projectID, "merge_commit_sha": shas}).
+		AndWhere(dbx.NewExp("merged_at != ''")).
+		All(&records)
+	return records, err
+}
+
+// isMergeRequestTargetBranch 判断 ref 是否为项目中某个MR的目标分支
+func isMergeRequestTargetBranch(app core.App, projectID int, ref string) bool {
+	_, err := app.FindFirstRecordByFilter(
+		"gitlab_merge_requests",
+		"project_id = {:projectID} && target_branch = {:ref}",
+		dbx.Params{"projectID": projectID, "ref": ref},
+	)
+	return err == nil
+}
+
+// formatDateTime 转换为 PocketBase 日期字段的存储格式，用于过滤条件比较
+func formatDateTime(t time.Time) string {
+	dt, _ := types.ParseDateTime(t)
+	return dt.String()
+}
+
+// parseDateParam 解析日期（2006-01-02）或RFC3339时间参数
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// median 返回中位数，空切片返回 0
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// mean 返回平均值，空切片返回 0
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,268 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// GitLabIssueEvent GitLab Issue Hook事件数据结构
+type GitLabIssueEvent struct {
+	ObjectKind       string          `json:"object_kind"`
+	EventType        string          `json:"event_type"`
+	User             User            `json:"user"`
+	Project          Project         `json:"project"`
+	ObjectAttributes IssueAttributes `json:"object_attributes"`
+	Assignees        []User          `json:"assignees"`
+	Labels           []Label         `json:"labels"`
+	Changes          json.RawMessage `json:"changes"`
+	Repository       Repository      `json:"repository"`
+}
+
+// IssueAttributes Issue Hook中的Issue属性
+type IssueAttributes struct {
+	ID           int          `json:"id"`
+	IID          int          `json:"iid"`
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	State        string       `json:"state"`
+	Action       string       `json:"action"`
+	AuthorID     int          `json:"author_id"`
+	AssigneeIDs  []int        `json:"assignee_ids"`
+	MilestoneID  int          `json:"milestone_id"`
+	DueDate      FlexibleTime `json:"due_date"`
+	Weight       int          `json:"weight"`
+	Confidential bool         `json:"confidential"`
+	Labels       []Label      `json:"labels"`
+	URL          string       `json:"url"`
+	CreatedAt    FlexibleTime `json:"created_at"`
+	UpdatedAt    FlexibleTime `json:"updated_at"`
+	ClosedAt     FlexibleTime `json:"closed_at"`
+}
+
+// handleIssuesEvent 处理Issues事件
+func handleIssuesEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabIssueEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse issue event", "error", err)
+		return e.BadRequestError("Invalid issue event format", err)
+	}
+
+	app.Logger().Info("Processing issue event",
+		"action", event.ObjectAttributes.Action,
+		"issueIID", event.ObjectAttributes.IID,
+		"title", event.ObjectAttributes.Title,
+		"state", event.ObjectAttributes.State,
+		"user", event.User.Username,
+		"projectName", event.Project.Name,
+	)
+
+	// 更新Issue当前状态并记录事件历史
+	if record, err := upsertIssue(app, event, body); err != nil {
+		app.Logger().Error("Failed to save issue record", "error", err)
+	} else {
+		app.Logger().Info("Issue record saved", "recordID", record.Id)
+
+		// 同步到映射的飞书多维表格
+		if client, ok := larkClientFromRequest(e); ok {
+			syncRecordToLark(app, client, record, larkMappingSourceIssue)
+		}
+	}
+
+	labels := event.Labels
+	if labels == nil {
+		labels = event.ObjectAttributes.Labels
+	}
+	dispatchNotification(e, notificationEvent{
+		EventType: e.Request.Header.Get("X-Gitlab-Event"),
+		Action:    event.ObjectAttributes.Action,
+		ProjectID: event.Project.ID,
+		Namespace: event.Project.PathWithNamespace,
+		Labels:    labelTitles(labels),
+		Author:    event.User.Username,
+		Title:     event.ObjectAttributes.Title,
+		URL:       event.ObjectAttributes.URL,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Issue event processed",
+		"event": map[string]interface{}{
+			"action":    event.ObjectAttributes.Action,
+			"issue_iid": event.ObjectAttributes.IID,
+			"title":     event.ObjectAttributes.Title,
+			"state":     event.ObjectAttributes.State,
+			"project":   event.Project.Name,
+		},
+	})
+}
+
+// upsertIssue 按 (project_id, iid) 更新或创建Issue当前状态记录，并追加一条事件历史
+func upsertIssue(app core.App, event GitLabIssueEvent, body []byte) (*core.Record, error) {
+	attrs := event.ObjectAttributes
+
+	record, err := findIssueRecord(app, event.Project.ID, attrs.IID)
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("gitlab_issues")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("iid", attrs.IID)
+		record.Set("project_id", event.Project.ID)
+	}
+	isNew := record.IsNew()
+
+	// 乱序到达的旧事件只记录历史，不覆盖当前状态
+	stale := !attrs.UpdatedAt.IsZero() && attrs.UpdatedAt.Before(record.GetDateTime("updated_at").Time())
+	if stale {
+		app.Logger().Info("Skipping stale issue state update",
+			"projectID", event.Project.ID,
+			"issueIID", attrs.IID,
+			"eventUpdatedAt", attrs.UpdatedAt.Time,
+			"storedUpdatedAt", record.GetDateTime("updated_at").String(),
+		)
+	} else if err := saveIssueState(app, record, event, body); err != nil {
+		return nil, err
+	}
+
+	// 先于Issue事件到达的评论在Issue首次入库时补上关联
+	if isNew {
+		linkIssueNotes(app, record)
+	}
+
+	// 记录事件历史
+	eventsCollection, err := app.FindCollectionByNameOrId("gitlab_issue_events")
+	if err != nil {
+		app.Logger().Warn("gitlab_issue_events collection not found", "error", err)
+		return record, nil
+	}
+
+	history := core.NewRecord(eventsCollection)
+	history.Set("issue", record.Id)
+	history.Set("iid", attrs.IID)
+	history.Set("project_id", event.Project.ID)
+	history.Set("action", attrs.Action)
+	history.Set("state", attrs.State)
+	history.Set("user_id", event.User.ID)
+	history.Set("user_username", event.User.Username)
+	if len(event.Changes) > 0 {
+		history.Set("changes", string(event.Changes))
+	}
+	history.Set("received_at", types.NowDateTime())
+	history.Set("event_data", string(body))
+
+	if err := app.Save(history); err != nil {
+		app.Logger().Error("Failed to save issue event history", "error", err, "recordID", record.Id)
+	}
+
+	return record, nil
+}
+
+// saveIssueState 将Issue状态写入当前状态记录
+func saveIssueState(app core.App, record *core.Record, event GitLabIssueEvent, body []byte) error {
+	attrs := event.ObjectAttributes
+
+	assignees := make([]map[string]interface{}, 0, len(event.Assignees))
+	for _, assignee := range event.Assignees {
+		assignees = append(assignees, map[string]interface{}{
+			"id":       assignee.ID,
+			"name":     assignee.Name,
+			"username": assignee.Username,
+		})
+	}
+
+	// 顶层 labels 缺失时退回 object_attributes 中的标签
+	labelSource := event.Labels
+	if labelSource == nil {
+		labelSource = attrs.Labels
+	}
+	labels := make([]string, 0, len(labelSource))
+	for _, label := range labelSource {
+		labels = append(labels, label.Title)
+	}
+
+	assigneeIDs := attrs.AssigneeIDs
+	if assigneeIDs == nil {
+		assigneeIDs = []int{}
+	}
+
+	record.Set("issue_id", attrs.ID)
+	record.Set("project_name", event.Project.Name)
+	record.Set("title", attrs.Title)
+	record.Set("description", attrs.Description)
+	record.Set("state", attrs.State)
+	record.Set("action", attrs.Action)
+	record.Set("author_id", attrs.AuthorID)
+	record.Set("assignee_ids", assigneeIDs)
+	record.Set("assignees", assignees)
+	record.Set("labels", labels)
+	record.Set("milestone_id", attrs.MilestoneID)
+	record.Set("due_date", dateOrEmpty(attrs.DueDate))
+	record.Set("weight", attrs.Weight)
+	record.Set("confidential", attrs.Confidential)
+	record.Set("url", attrs.URL)
+	if !attrs.CreatedAt.IsZero() {
+		record.Set("created_at", attrs.CreatedAt.Time)
+	}
+	if !attrs.UpdatedAt.IsZero() {
+		record.Set("updated_at", attrs.UpdatedAt.Time)
+	}
+	record.Set("closed_at", dateOrEmpty(attrs.ClosedAt))
+	record.Set("event_data", string(body))
+
+	return app.Save(record)
+}
+
+// findIssueRecord 按 (project_id, iid) 查询Issue当前状态记录
+func findIssueRecord(app core.App, projectID, iid int) (*core.Record, error) {
+	return app.FindFirstRecordByFilter(
+		"gitlab_issues",
+		"project_id = {:projectID} && iid = {:iid}",
+		dbx.Params{"projectID": projectID, "iid": iid},
+	)
+}
+
+// linkIssueNotes 为尚未关联Issue的评论事件补上关联
+func linkIssueNotes(app core.App, issue *core.Record) {
+	notes, err := app.FindRecordsByFilter(
+		"gitlab_note_events",
+		"noteable_type = 'Issue' && project_id = {:projectID} && noteable_id = {:iid} && issue = ''",
+		"",
+		0,
+		0,
+		dbx.Params{
+			"projectID": issue.GetInt("project_id"),
+			"iid":       fmt.Sprint(issue.GetInt("iid")),
+		},
+	)
+	if err != nil {
+		app.Logger().Error("Failed to load issue notes", "error", err, "recordID", issue.Id)
+		return
+	}
+
+	for _, note := range notes {
+		note.Set("issue", issue.Id)
+		if err := app.Save(note); err != nil {
+			app.Logger().Error("Failed to link note to issue", "error", err, "noteRecordID", note.Id)
+		}
+	}
+
+	if len(notes) > 0 {
+		app.Logger().Info("Linked notes to issue", "recordID", issue.Id, "notes", len(notes))
+	}
+}
+
+// dateOrEmpty 零值时间返回空值以清空日期字段
+func dateOrEmpty(t FlexibleTime) interface{} {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Time
+}
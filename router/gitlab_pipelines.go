@@ -0,0 +1,504 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// GitLabPipelineEvent GitLab Pipeline Hook事件数据结构
+type GitLabPipelineEvent struct {
+	ObjectKind       string                `json:"object_kind"`
+	ObjectAttributes PipelineAttributes    `json:"object_attributes"`
+	MergeRequest     *PipelineMergeRequest `json:"merge_request"`
+	User             User                  `json:"user"`
+	Project          Project               `json:"project"`
+	Commit           Commit                `json:"commit"`
+	Builds           []PipelineBuild       `json:"builds"`
+}
+
+// PipelineBuild Pipeline Hook中的作业信息，只解析判断事件先后需要的时间
+type PipelineBuild struct {
+	ID         int          `json:"id"`
+	Status     string       `json:"status"`
+	CreatedAt  FlexibleTime `json:"created_at"`
+	StartedAt  FlexibleTime `json:"started_at"`
+	FinishedAt FlexibleTime `json:"finished_at"`
+}
+
+// pipelineFinishedStatuses 流水线的终态
+var pipelineFinishedStatuses = map[string]bool{
+	"success":  true,
+	"failed":   true,
+	"canceled": true,
+	"skipped":  true,
+}
+
+// PipelineAttributes Pipeline Hook中的流水线属性
+type PipelineAttributes struct {
+	ID             int          `json:"id"`
+	IID            int          `json:"iid"`
+	Ref            string       `json:"ref"`
+	Tag            bool         `json:"tag"`
+	SHA            string       `json:"sha"`
+	BeforeSHA      string       `json:"before_sha"`
+	Source         string       `json:"source"`
+	Status         string       `json:"status"`
+	DetailedStatus string       `json:"detailed_status"`
+	Stages         []string     `json:"stages"`
+	CreatedAt      FlexibleTime `json:"created_at"`
+	FinishedAt     FlexibleTime `json:"finished_at"`
+	Duration       float64      `json:"duration"`
+	QueuedDuration float64      `json:"queued_duration"`
+	URL            string       `json:"url"`
+}
+
+// PipelineMergeRequest Pipeline Hook中关联的MR信息
+type PipelineMergeRequest struct {
+	ID              int    `json:"id"`
+	IID             int    `json:"iid"`
+	Title           string `json:"title"`
+	SourceBranch    string `json:"source_branch"`
+	SourceProjectID int    `json:"source_project_id"`
+	TargetBranch    string `json:"target_branch"`
+	TargetProjectID int    `json:"target_project_id"`
+	State           string `json:"state"`
+	URL             string `json:"url"`
+}
+
+// GitLabJobEvent GitLab Job Hook事件数据结构
+type GitLabJobEvent struct {
+	ObjectKind          string       `json:"object_kind"`
+	Ref                 string       `json:"ref"`
+	Tag                 bool         `json:"tag"`
+	BeforeSHA           string       `json:"before_sha"`
+	SHA                 string       `json:"sha"`
+	BuildID             int          `json:"build_id"`
+	BuildName           string       `json:"build_name"`
+	BuildStage          string       `json:"build_stage"`
+	BuildStatus         string       `json:"build_status"`
+	BuildCreatedAt      FlexibleTime `json:"build_created_at"`
+	BuildStartedAt      FlexibleTime `json:"build_started_at"`
+	BuildFinishedAt     FlexibleTime `json:"build_finished_at"`
+	BuildDuration       float64      `json:"build_duration"`
+	BuildQueuedDuration float64      `json:"build_queued_duration"`
+	BuildAllowFailure   bool         `json:"build_allow_failure"`
+	BuildFailureReason  string       `json:"build_failure_reason"`
+	PipelineID          int          `json:"pipeline_id"`
+	Runner              *JobRunner   `json:"runner"`
+	ProjectID           int          `json:"project_id"`
+	ProjectName         string       `json:"project_name"`
+	Project             Project      `json:"project"`
+	User                User         `json:"user"`
+}
+
+// JobRunner Job Hook中的Runner信息
+type JobRunner struct {
+	ID          int      `json:"id"`
+	Description string   `json:"description"`
+	Active      bool     `json:"active"`
+	IsShared    bool     `json:"is_shared"`
+	Tags        []string `json:"tags"`
+}
+
+// statusTransition 流水线或作业的一次状态变化
+type statusTransition struct {
+	Status string `json:"status"`
+	At     string `json:"at"`
+}
+
+// handlePipelineEvent 处理Pipeline事件
+func handlePipelineEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabPipelineEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse pipeline event", "error", err)
+		return e.BadRequestError("Invalid pipeline event format", err)
+	}
+
+	attrs := event.ObjectAttributes
+
+	app.Logger().Info("Processing pipeline event",
+		"pipelineID", attrs.ID,
+		"status", attrs.Status,
+		"ref", attrs.Ref,
+		"source", attrs.Source,
+		"projectName", event.Project.Name,
+	)
+
+	if record, err := upsertPipeline(app, event, body); err != nil {
+		app.Logger().Error("Failed to save pipeline record", "error", err)
+	} else {
+		app.Logger().Info("Pipeline record saved", "recordID", record.Id)
+
+		// 更新关联MR已发送的飞书卡片中的流水线状态
+		if mrID := record.GetString("merge_request"); mrID != "" {
+			if client, ok := larkClientFromRequest(e); ok {
+				if mr, err := app.FindRecordById("gitlab_merge_requests", mrID); err == nil {
+					refreshMergeRequestCard(app, client, mr)
+				}
+			}
+		}
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Pipeline Hook",
+		Action:    attrs.Status,
+		ProjectID: event.Project.ID,
+		Namespace: event.Project.PathWithNamespace,
+		Branch:    attrs.Ref,
+		Author:    event.User.Username,
+		Title:     event.Commit.Title,
+		URL:       attrs.URL,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Pipeline event processed",
+		"event": map[string]interface{}{
+			"pipeline_id": attrs.ID,
+			"status":      attrs.Status,
+			"ref":         attrs.Ref,
+			"project":     event.Project.Name,
+		},
+	})
+}
+
+// upsertPipeline 按 pipeline_id 更新或创建流水线记录，并同步关联MR的流水线状态
+func upsertPipeline(app core.App, event GitLabPipelineEvent, body []byte) (*core.Record, error) {
+	attrs := event.ObjectAttributes
+
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_pipelines",
+		"pipeline_id = {:pipelineID}",
+		dbx.Params{"pipelineID": attrs.ID},
+	)
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("gitlab_pipelines")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("pipeline_id", attrs.ID)
+	}
+	isNew := record.IsNew()
+
+	// 乱序到达的旧状态不覆盖当前状态：事件时间早于已保存的状态，
+	// 或时间相同但已保存终态而事件不是终态（如迟到的 running）时忽略
+	changedAt := pipelineEventTime(event)
+	if stored := record.GetDateTime("status_changed_at"); !isNew && !stored.IsZero() &&
+		(changedAt.Before(stored) ||
+			changedAt.Equal(stored) && pipelineFinishedStatuses[record.GetString("status")] && !pipelineFinishedStatuses[attrs.Status]) {
+		app.Logger().Info("Skipping stale pipeline status update",
+			"pipelineID", attrs.ID,
+			"eventStatus", attrs.Status,
+			"storedStatus", record.GetString("status"),
+		)
+		return record, nil
+	}
+
+	record.Set("pipeline_iid", attrs.IID)
+	record.Set("project_id", event.Project.ID)
+	record.Set("project_name", event.Project.Name)
+	record.Set("ref", attrs.Ref)
+	record.Set("tag", attrs.Tag)
+	record.Set("sha", attrs.SHA)
+	record.Set("before_sha", attrs.BeforeSHA)
+	record.Set("source", attrs.Source)
+	record.Set("status", attrs.Status)
+	record.Set("detailed_status", attrs.DetailedStatus)
+	record.Set("stages", attrs.Stages)
+	record.Set("duration", attrs.Duration)
+	record.Set("queued_duration", attrs.QueuedDuration)
+	record.Set("user_id", event.User.ID)
+	record.Set("user_username", event.User.Username)
+	record.Set("url", attrs.URL)
+	if !attrs.CreatedAt.IsZero() {
+		record.Set("created_at", attrs.CreatedAt.Time)
+	}
+	record.Set("finished_at", dateOrEmpty(attrs.FinishedAt))
+	record.Set("status_changed_at", changedAt)
+	record.Set("event_data", string(body))
+	appendStatusHistory(record, attrs.Status)
+
+	mr := findPipelineMergeRequest(app, event)
+	if mr != nil {
+		record.Set("merge_request", mr.Id)
+		record.Set("mr_iid", mr.GetInt("mr_iid"))
+	}
+
+	if err := app.Save(record); err != nil {
+		return nil, err
+	}
+
+	// 先于Pipeline事件到达的作业在流水线首次入库时补上关联
+	if isNew {
+		linkPipelineJobs(app, record)
+	}
+
+	if mr != nil {
+		updateMergeRequestPipeline(app, mr, record)
+	}
+
+	return record, nil
+}
+
+// pipelineEventTime 返回流水线事件中最近一次状态变化的时间
+//
+// Pipeline Hook 不携带 updated_at，取流水线创建、结束时间和各作业创建、开始、结束时间中最晚的一个；
+// 都没有时使用当前时间。
+func pipelineEventTime(event GitLabPipelineEvent) types.DateTime {
+	latest := event.ObjectAttributes.CreatedAt.Time
+	candidates := []FlexibleTime{event.ObjectAttributes.FinishedAt}
+	for _, build := range event.Builds {
+		candidates = append(candidates, build.CreatedAt, build.StartedAt, build.FinishedAt)
+	}
+	for _, candidate := range candidates {
+		if candidate.After(latest) {
+			latest = candidate.Time
+		}
+	}
+
+	if latest.IsZero() {
+		return types.NowDateTime()
+	}
+	changedAt, _ := types.ParseDateTime(latest)
+	return changedAt
+}
+
+// findPipelineMergeRequest 查找流水线对应的MR
+//
+// MR流水线直接使用事件中的 merge_request；分支流水线匹配源分支为该 ref 的打开状态MR。
+func findPipelineMergeRequest(app core.App, event GitLabPipelineEvent) *core.Record {
+	if event.MergeRequest != nil && event.MergeRequest.IID > 0 {
+		projectID := event.MergeRequest.TargetProjectID
+		if projectID == 0 {
+			projectID = event.Project.ID
+		}
+
+		record, err := app.FindFirstRecordByFilter(
+			"gitlab_merge_requests",
+			"project_id = {:projectID} && mr_iid = {:iid}",
+			dbx.Params{"projectID": projectID, "iid": event.MergeRequest.IID},
+		)
+		if err != nil {
+			return nil
+		}
+		return record
+	}
+
+	if event.ObjectAttributes.Tag || event.ObjectAttributes.Ref == "" {
+		return nil
+	}
+
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_merge_requests",
+		"project_id = {:projectID} && source_branch = {:ref} && state = 'opened'",
+		dbx.Params{"projectID": event.Project.ID, "ref": event.ObjectAttributes.Ref},
+	)
+	if err != nil {
+		return nil
+	}
+	return record
+}
+
+// updateMergeRequestPipeline 将流水线状态写入MR，较旧流水线的事件不会覆盖最新流水线
+func updateMergeRequestPipeline(app core.App, mr, pipeline *core.Record) {
+	pipelineID := pipeline.GetInt("pipeline_id")
+	if pipelineID < mr.GetInt("head_pipeline_id") {
+		return
+	}
+
+	mr.Set("head_pipeline", pipeline.Id)
+	mr.Set("head_pipeline_id", pipelineID)
+	mr.Set("pipeline_status", pipeline.GetString("status"))
+
+	if err := app.Save(mr); err != nil {
+		app.Logger().Error("Failed to update merge request pipeline status", "error", err, "recordID", mr.Id)
+	}
+}
+
+// handleJobEvent 处理Job事件
+func handleJobEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabJobEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse job event", "error", err)
+		return e.BadRequestError("Invalid job event format", err)
+	}
+
+	app.Logger().Info("Processing job event",
+		"jobID", event.BuildID,
+		"name", event.BuildName,
+		"stage", event.BuildStage,
+		"status", event.BuildStatus,
+		"pipelineID", event.PipelineID,
+		"projectName", event.ProjectName,
+	)
+
+	if record, err := upsertJob(app, event, body); err != nil {
+		app.Logger().Error("Failed to save job record", "error", err)
+	} else {
+		app.Logger().Info("Job record saved", "recordID", record.Id)
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Job Hook",
+		Action:    event.BuildStatus,
+		ProjectID: event.ProjectID,
+		Namespace: event.Project.PathWithNamespace,
+		Branch:    event.Ref,
+		Author:    event.User.Username,
+		Title:     event.BuildName,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Job event processed",
+		"event": map[string]interface{}{
+			"job_id":      event.BuildID,
+			"name":        event.BuildName,
+			"status":      event.BuildStatus,
+			"pipeline_id": event.PipelineID,
+			"project":     event.ProjectName,
+		},
+	})
+}
+
+// upsertJob 按 job_id 更新或创建作业记录
+func upsertJob(app core.App, event GitLabJobEvent, body []byte) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_jobs",
+		"job_id = {:jobID}",
+		dbx.Params{"jobID": event.BuildID},
+	)
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("gitlab_jobs")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("job_id", event.BuildID)
+	}
+
+	// 与流水线相同，乱序到达的旧状态不覆盖当前状态
+	changedAt := jobEventTime(event)
+	if stored := record.GetDateTime("status_changed_at"); !record.IsNew() && !stored.IsZero() &&
+		(changedAt.Before(stored) ||
+			changedAt.Equal(stored) && pipelineFinishedStatuses[record.GetString("status")] && !pipelineFinishedStatuses[event.BuildStatus]) {
+		app.Logger().Info("Skipping stale job status update",
+			"jobID", event.BuildID,
+			"eventStatus", event.BuildStatus,
+			"storedStatus", record.GetString("status"),
+		)
+		return record, nil
+	}
+
+	record.Set("pipeline_id", event.PipelineID)
+	record.Set("project_id", event.ProjectID)
+	record.Set("name", event.BuildName)
+	record.Set("stage", event.BuildStage)
+	record.Set("status", event.BuildStatus)
+	record.Set("duration", event.BuildDuration)
+	record.Set("queued_duration", event.BuildQueuedDuration)
+	record.Set("failure_reason", event.BuildFailureReason)
+	record.Set("allow_failure", event.BuildAllowFailure)
+	if event.Runner != nil {
+		record.Set("runner_id", event.Runner.ID)
+		record.Set("runner_description", event.Runner.Description)
+	}
+	record.Set("ref", event.Ref)
+	record.Set("sha", event.SHA)
+	record.Set("user_username", event.User.Username)
+	if !event.BuildCreatedAt.IsZero() {
+		record.Set("created_at", event.BuildCreatedAt.Time)
+	}
+	record.Set("started_at", dateOrEmpty(event.BuildStartedAt))
+	record.Set("finished_at", dateOrEmpty(event.BuildFinishedAt))
+	record.Set("status_changed_at", changedAt)
+	record.Set("event_data", string(body))
+	appendStatusHistory(record, event.BuildStatus)
+
+	if record.GetString("pipeline") == "" {
+		pipeline, err := app.FindFirstRecordByFilter(
+			"gitlab_pipelines",
+			"pipeline_id = {:pipelineID}",
+			dbx.Params{"pipelineID": event.PipelineID},
+		)
+		if err == nil {
+			record.Set("pipeline", pipeline.Id)
+		}
+	}
+
+	if err := app.Save(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// jobEventTime 作业事件对应的状态变化时间，取创建、开始、结束时间中最晚的一个
+func jobEventTime(event GitLabJobEvent) types.DateTime {
+	latest := event.BuildCreatedAt.Time
+	for _, candidate := range []FlexibleTime{event.BuildStartedAt, event.BuildFinishedAt} {
+		if candidate.After(latest) {
+			latest = candidate.Time
+		}
+	}
+
+	if latest.IsZero() {
+		return types.NowDateTime()
+	}
+	changedAt, _ := types.ParseDateTime(latest)
+	return changedAt
+}
+
+// linkPipelineJobs 为尚未关联流水线的作业补上关联
+func linkPipelineJobs(app core.App, pipeline *core.Record) {
+	jobs, err := app.FindRecordsByFilter(
+		"gitlab_jobs",
+		"pipeline_id = {:pipelineID} && pipeline = ''",
+		"",
+		0,
+		0,
+		dbx.Params{"pipelineID": pipeline.GetInt("pipeline_id")},
+	)
+	if err != nil {
+		app.Logger().Error("Failed to load pipeline jobs", "error", err, "recordID", pipeline.Id)
+		return
+	}
+
+	for _, job := range jobs {
+		job.Set("pipeline", pipeline.Id)
+		if err := app.Save(job); err != nil {
+			app.Logger().Error("Failed to link job to pipeline", "error", err, "jobRecordID", job.Id)
+		}
+	}
+}
+
+// appendStatusHistory 状态与上一次记录不同时追加一条状态变化
+func appendStatusHistory(record *core.Record, status string) {
+	if status == "" {
+		return
+	}
+
+	var history []statusTransition
+	if err := record.UnmarshalJSONField("status_history", &history); err != nil {
+		history = nil
+	}
+
+	if len(history) > 0 && history[len(history)-1].Status == status {
+		return
+	}
+
+	history = append(history, statusTransition{
+		Status: status,
+		At:     types.NowDateTime().String(),
+	})
+	record.Set("status_history", history)
+}
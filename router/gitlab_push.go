@@ -0,0 +1,206 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// GitLabPushEvent GitLab Push Hook / Tag Push Hook事件数据结构
+type GitLabPushEvent struct {
+	ObjectKind        string     `json:"object_kind"`
+	EventName         string     `json:"event_name"`
+	Before            string     `json:"before"`
+	After             string     `json:"after"`
+	Ref               string     `json:"ref"`
+	RefProtected      bool       `json:"ref_protected"`
+	CheckoutSHA       string     `json:"checkout_sha"`
+	Message           string     `json:"message"`
+	UserID            int        `json:"user_id"`
+	UserName          string     `json:"user_name"`
+	UserUsername      string     `json:"user_username"`
+	UserEmail         string     `json:"user_email"`
+	UserAvatar        string     `json:"user_avatar"`
+	ProjectID         int        `json:"project_id"`
+	Project           Project    `json:"project"`
+	Repository        Repository `json:"repository"`
+	Commits           []Commit   `json:"commits"`
+	TotalCommitsCount int        `json:"total_commits_count"`
+}
+
+// handlePushEvent 处理Push事件
+func handlePushEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabPushEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse push event", "error", err)
+		return e.BadRequestError("Invalid push event format", err)
+	}
+
+	branch := strings.TrimPrefix(event.Ref, "refs/heads/")
+
+	app.Logger().Info("Processing push event",
+		"projectID", event.ProjectID,
+		"projectName", event.Project.Name,
+		"ref", event.Ref,
+		"before", event.Before,
+		"after", event.After,
+		"totalCommits", event.TotalCommitsCount,
+		"user", event.UserUsername,
+	)
+
+	pushedAt := types.NowDateTime()
+	newCommits := 0
+
+	// 保存推送事件到数据库
+	collection, err := app.FindCollectionByNameOrId("gitlab_push_events")
+	if err != nil {
+		app.Logger().Warn("gitlab_push_events collection not found", "error", err)
+	} else {
+		record := core.NewRecord(collection)
+		record.Set("project_id", event.ProjectID)
+		record.Set("project_name", event.Project.Name)
+		record.Set("ref", event.Ref)
+		record.Set("branch", branch)
+		record.Set("before", event.Before)
+		record.Set("after", event.After)
+		record.Set("checkout_sha", event.CheckoutSHA)
+		record.Set("total_commits_count", event.TotalCommitsCount)
+		record.Set("user_id", event.UserID)
+		record.Set("user_name", event.UserName)
+		record.Set("user_username", event.UserUsername)
+		record.Set("user_email", event.UserEmail)
+		record.Set("pushed_at", pushedAt)
+		record.Set("event_data", string(body))
+
+		if err := app.Save(record); err != nil {
+			app.Logger().Error("Failed to save push event", "error", err)
+		} else {
+			app.Logger().Info("Push event saved", "recordID", record.Id)
+			newCommits = saveCommits(app, event, branch, record.Id, pushedAt)
+		}
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Push Hook",
+		ProjectID: event.ProjectID,
+		Namespace: event.Project.PathWithNamespace,
+		Branch:    branch,
+		Author:    event.UserUsername,
+		Title:     event.Message,
+		URL:       event.Project.WebURL,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Push event processed",
+		"event": map[string]interface{}{
+			"project_id":    event.ProjectID,
+			"ref":           event.Ref,
+			"after":         event.After,
+			"total_commits": event.TotalCommitsCount,
+			"new_commits":   newCommits,
+		},
+	})
+}
+
+// saveCommits 保存推送中的提交并记录提交所在的引用，返回新增提交数
+//
+// 同一提交可能随多次推送（如合并或快进到其他分支）重复出现：gitlab_commits 按 SHA 只保存一行，
+// 每个新出现的引用追加一行 gitlab_commit_refs。
+func saveCommits(app core.App, event GitLabPushEvent, branch, pushEventID string, pushedAt types.DateTime) int {
+	collection, err := app.FindCollectionByNameOrId("gitlab_commits")
+	if err != nil {
+		app.Logger().Warn("gitlab_commits collection not found", "error", err)
+		return 0
+	}
+
+	saved := 0
+	for _, commit := range event.Commits {
+		if commit.ID == "" {
+			continue
+		}
+
+		existing, _ := app.FindFirstRecordByFilter(
+			collection,
+			"project_id = {:projectID} && sha = {:sha}",
+			dbx.Params{"projectID": event.ProjectID, "sha": commit.ID},
+		)
+		if existing != nil {
+			saveCommitRef(app, existing, event.Ref, branch, pushEventID, pushedAt)
+			continue
+		}
+
+		record := core.NewRecord(collection)
+		record.Set("sha", commit.ID)
+		record.Set("project_id", event.ProjectID)
+		record.Set("push_event", pushEventID)
+		record.Set("ref", event.Ref)
+		record.Set("branch", branch)
+		record.Set("title", commit.Title)
+		record.Set("message", commit.Message)
+		record.Set("author_name", commit.Author.Name)
+		record.Set("author_email", commit.Author.Email)
+		if !commit.Timestamp.IsZero() {
+			record.Set("committed_at", commit.Timestamp.Time)
+		}
+		record.Set("pushed_at", pushedAt)
+		record.Set("url", commit.URL)
+		record.Set("added", commit.Added)
+		record.Set("modified", commit.Modified)
+		record.Set("removed", commit.Removed)
+
+		if err := app.Save(record); err != nil {
+			app.Logger().Error("Failed to save commit", "error", err, "sha", commit.ID)
+			continue
+		}
+		saved++
+
+		saveCommitRef(app, record, event.Ref, branch, pushEventID, pushedAt)
+	}
+
+	app.Logger().Info("Commits saved",
+		"projectID", event.ProjectID,
+		"ref", event.Ref,
+		"received", len(event.Commits),
+		"new", saved,
+	)
+
+	return saved
+}
+
+// saveCommitRef 记录提交出现在引用上，已记录的 (提交, 引用) 被跳过
+func saveCommitRef(app core.App, commit *core.Record, ref, branch, pushEventID string, pushedAt types.DateTime) {
+	collection, err := app.FindCollectionByNameOrId("gitlab_commit_refs")
+	if err != nil {
+		app.Logger().Warn("gitlab_commit_refs collection not found", "error", err)
+		return
+	}
+
+	existing, _ := app.FindFirstRecordByFilter(
+		collection,
+		"project_id = {:projectID} && sha = {:sha} && ref = {:ref}",
+		dbx.Params{"projectID": commit.GetInt("project_id"), "sha": commit.GetString("sha"), "ref": ref},
+	)
+	if existing != nil {
+		return
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("commit", commit.Id)
+	record.Set("project_id", commit.GetInt("project_id"))
+	record.Set("sha", commit.GetString("sha"))
+	record.Set("ref", ref)
+	record.Set("branch", branch)
+	record.Set("push_event", pushEventID)
+	record.Set("pushed_at", pushedAt)
+
+	if err := app.Save(record); err != nil {
+		app.Logger().Error("Failed to save commit ref", "error", err, "sha", commit.GetString("sha"), "ref", ref)
+	}
+}
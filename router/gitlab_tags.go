@@ -0,0 +1,406 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// gitlabZeroSHA Push事件中表示引用不存在的SHA
+const gitlabZeroSHA = "0000000000000000000000000000000000000000"
+
+// semverPattern 语义化版本，允许 v 前缀，构建元数据会被忽略
+var semverPattern = regexp.MustCompile(`^[vV]?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// Semver 解析后的语义化版本
+type Semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseSemver 解析标签名中的语义化版本
+func ParseSemver(tag string) (Semver, bool) {
+	matches := semverPattern.FindStringSubmatch(tag)
+	if matches == nil {
+		return Semver{}, false
+	}
+
+	major, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return Semver{}, false
+	}
+	minor, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return Semver{}, false
+	}
+	patch, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return Semver{}, false
+	}
+
+	return Semver{Major: major, Minor: minor, Patch: patch, Prerelease: matches[4]}, true
+}
+
+// Compare 按语义化版本规则比较，返回 -1、0 或 1
+func (v Semver) Compare(other Semver) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+
+	// 正式版本高于同版本号的预发布版本
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+
+	a := strings.Split(v.Prerelease, ".")
+	b := strings.Split(other.Prerelease, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePrereleaseIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// comparePrereleaseIdentifier 比较预发布标识：数字按数值比较且低于非数字标识
+func comparePrereleaseIdentifier(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// String 返回不带 v 前缀的版本号
+func (v Semver) String() string {
+	s := strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// handleTagPushEvent 处理Tag Push事件
+func handleTagPushEvent(e *core.RequestEvent, body []byte) error {
+	app := e.App
+
+	var event GitLabPushEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse tag push event", "error", err)
+		return e.BadRequestError("Invalid tag push event format", err)
+	}
+
+	tagName := strings.TrimPrefix(event.Ref, "refs/tags/")
+	deleted := event.After == gitlabZeroSHA
+
+	app.Logger().Info("Processing tag push event",
+		"projectID", event.ProjectID,
+		"projectName", event.Project.Name,
+		"tag", tagName,
+		"checkoutSHA", event.CheckoutSHA,
+		"deleted", deleted,
+		"user", event.UserUsername,
+	)
+
+	action := "created"
+	if deleted {
+		action = "deleted"
+	}
+
+	if record, err := saveTag(app, event, tagName, deleted, body); err != nil {
+		app.Logger().Error("Failed to save tag record", "error", err, "tag", tagName)
+	} else if record != nil {
+		app.Logger().Info("Tag record saved", "recordID", record.Id, "tag", tagName, "action", action)
+	}
+
+	dispatchNotification(e, notificationEvent{
+		EventType: "Tag Push Hook",
+		Action:    action,
+		ProjectID: event.ProjectID,
+		Namespace: event.Project.PathWithNamespace,
+		Author:    event.UserUsername,
+		Title:     tagName,
+		URL:       event.Project.WebURL,
+	}, body)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Tag push event processed",
+		"event": map[string]interface{}{
+			"project_id": event.ProjectID,
+			"tag":        tagName,
+			"action":     action,
+		},
+	})
+}
+
+// saveTag 按 (project_id, tag_name) 更新或创建标签记录，删除事件只做软删除
+func saveTag(app core.App, event GitLabPushEvent, tagName string, deleted bool, body []byte) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_tags",
+		"project_id = {:projectID} && tag_name = {:tagName}",
+		dbx.Params{"projectID": event.ProjectID, "tagName": tagName},
+	)
+	if err != nil {
+		if deleted {
+			// 未记录过的标签被删除，无需处理
+			app.Logger().Info("Deleted tag not found, skipping", "projectID", event.ProjectID, "tag", tagName)
+			return nil, nil
+		}
+
+		collection, err := app.FindCollectionByNameOrId("gitlab_tags")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("project_id", event.ProjectID)
+		record.Set("tag_name", tagName)
+	}
+
+	if deleted {
+		record.Set("deleted", true)
+		record.Set("deleted_at", types.NowDateTime())
+		return record, app.Save(record)
+	}
+
+	version, isSemver := ParseSemver(tagName)
+
+	record.Set("project_name", event.Project.Name)
+	record.Set("ref", event.Ref)
+	record.Set("target_sha", event.CheckoutSHA)
+	record.Set("message", event.Message)
+	record.Set("user_id", event.UserID)
+	record.Set("user_name", event.UserName)
+	record.Set("user_username", event.UserUsername)
+	record.Set("user_email", event.UserEmail)
+	record.Set("is_semver", isSemver)
+	record.Set("semver_major", version.Major)
+	record.Set("semver_minor", version.Minor)
+	record.Set("semver_patch", version.Patch)
+	record.Set("semver_prerelease", version.Prerelease)
+	record.Set("deleted", false)
+	record.Set("deleted_at", "")
+	record.Set("pushed_at", types.NowDateTime())
+	record.Set("event_data", string(body))
+
+	return record, app.Save(record)
+}
+
+// GitLabLatestRelease 返回项目的最新发布版本（语义化版本最大的未删除标签）
+//
+// 默认忽略预发布版本，include_prerelease=true 时一并参与比较。
+func GitLabLatestRelease(e *core.RequestEvent) error {
+	app := e.App
+
+	projectID, err := strconv.Atoi(e.Request.PathValue("projectID"))
+	if err != nil {
+		return e.BadRequestError("Invalid project ID", err)
+	}
+
+	includePrerelease := e.Request.URL.Query().Get("include_prerelease") == "true"
+
+	records, err := app.FindRecordsByFilter(
+		"gitlab_tags",
+		"project_id = {:projectID} && is_semver = true && deleted = false",
+		"",
+		0,
+		0,
+		dbx.Params{"projectID": projectID},
+	)
+	if err != nil {
+		return e.InternalServerError("Failed to load tags", err)
+	}
+
+	var latest *core.Record
+	var latestVersion Semver
+	for _, record := range records {
+		version := tagSemver(record)
+		if version.Prerelease != "" && !includePrerelease {
+			continue
+		}
+		if latest == nil || version.Compare(latestVersion) > 0 {
+			latest = record
+			latestVersion = version
+		}
+	}
+
+	if latest == nil {
+		return e.NotFoundError("No release found", nil)
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"release": tagResponse(latest, latestVersion),
+	})
+}
+
+// GitLabTagCompare 返回两个标签之间合入的MR
+//
+// 以标签指向提交的推送时间为边界，取区间 (from, to] 内推送的提交，
+// 再按 merge_commit_sha 匹配MR。标签指向的提交未被记录时退化为标签的推送时间。
+func GitLabTagCompare(e *core.RequestEvent) error {
+	app := e.App
+
+	projectID, err := strconv.Atoi(e.Request.PathValue("projectID"))
+	if err != nil {
+		return e.BadRequestError("Invalid project ID", err)
+	}
+
+	query := e.Request.URL.Query()
+	fromName := query.Get("from")
+	toName := query.Get("to")
+	if fromName == "" || toName == "" {
+		return e.BadRequestError("from and to are required", nil)
+	}
+
+	fromTag, err := findTag(app, projectID, fromName)
+	if err != nil {
+		return e.NotFoundError("Tag not found: "+fromName, err)
+	}
+	toTag, err := findTag(app, projectID, toName)
+	if err != nil {
+		return e.NotFoundError("Tag not found: "+toName, err)
+	}
+
+	from := tagBoundary(app, projectID, fromTag)
+	to := tagBoundary(app, projectID, toTag)
+	if !from.Before(to) {
+		return e.BadRequestError("from must be older than to", nil)
+	}
+
+	commits, err := app.FindRecordsByFilter(
+		"gitlab_commits",
+		"project_id = {:projectID} && pushed_at > {:from} && pushed_at <= {:to}",
+		"",
+		0,
+		0,
+		dbx.Params{"projectID": projectID, "from": from.String(), "to": to.String()},
+	)
+	if err != nil {
+		return e.InternalServerError("Failed to load commits", err)
+	}
+
+	mergeRequests := []map[string]interface{}{}
+	if len(commits) > 0 {
+		shas := make([]interface{}, 0, len(commits))
+		for _, commit := range commits {
+			shas = append(shas, commit.GetString("sha"))
+		}
+
+		var records []*core.Record
+		err := app.RecordQuery("gitlab_merge_requests").
+			AndWhere(dbx.HashExp{"project_id": projectID, "merge_commit_sha": shas}).
+			OrderBy("updated_at ASC").
+			All(&records)
+		if err != nil {
+			return e.InternalServerError("Failed to load merge requests", err)
+		}
+
+		for _, record := range records {
+			mergeRequests = append(mergeRequests, map[string]interface{}{
+				"mr_iid":           record.GetInt("mr_iid"),
+				"title":            record.GetString("title"),
+				"author_username":  record.GetString("author_username"),
+				"source_branch":    record.GetString("source_branch"),
+				"target_branch":    record.GetString("target_branch"),
+				"merge_commit_sha": record.GetString("merge_commit_sha"),
+				"url":              record.GetString("url"),
+			})
+		}
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":         "success",
+		"project_id":     projectID,
+		"from":           tagResponse(fromTag, tagSemver(fromTag)),
+		"to":             tagResponse(toTag, tagSemver(toTag)),
+		"commits_count":  len(commits),
+		"merge_requests": mergeRequests,
+	})
+}
+
+// findTag 查询项目中未删除的标签
+func findTag(app core.App, projectID int, tagName string) (*core.Record, error) {
+	return app.FindFirstRecordByFilter(
+		"gitlab_tags",
+		"project_id = {:projectID} && tag_name = {:tagName} && deleted = false",
+		dbx.Params{"projectID": projectID, "tagName": tagName},
+	)
+}
+
+// tagBoundary 返回标签指向提交的推送时间，提交未被记录时使用标签的推送时间
+func tagBoundary(app core.App, projectID int, tag *core.Record) types.DateTime {
+	commit, err := app.FindFirstRecordByFilter(
+		"gitlab_commits",
+		"project_id = {:projectID} && sha = {:sha}",
+		dbx.Params{"projectID": projectID, "sha": tag.GetString("target_sha")},
+	)
+	if err == nil && !commit.GetDateTime("pushed_at").IsZero() {
+		return commit.GetDateTime("pushed_at")
+	}
+	return tag.GetDateTime("pushed_at")
+}
+
+// tagSemver 从标签记录中读取语义化版本
+func tagSemver(record *core.Record) Semver {
+	return Semver{
+		Major:      record.GetInt("semver_major"),
+		Minor:      record.GetInt("semver_minor"),
+		Patch:      record.GetInt("semver_patch"),
+		Prerelease: record.GetString("semver_prerelease"),
+	}
+}
+
+// tagResponse 构造标签的响应数据
+func tagResponse(record *core.Record, version Semver) map[string]interface{} {
+	data := map[string]interface{}{
+		"tag_name":      record.GetString("tag_name"),
+		"target_sha":    record.GetString("target_sha"),
+		"message":       record.GetString("message"),
+		"user_username": record.GetString("user_username"),
+		"pushed_at":     record.GetDateTime("pushed_at").String(),
+	}
+	if record.GetBool("is_semver") {
+		data["version"] = version.String()
+	}
+	return data
+}
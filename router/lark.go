@@ -3,7 +3,10 @@ package router
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
 	"github.com/pocketbase/pocketbase/core"
@@ -88,22 +91,34 @@ func LarkBaseTable(e *core.RequestEvent) error {
 
 	app.Logger().Info("Processing record", "recordID", recordID)
 
+	// 优先使用缓存的解析结果
+	if cached := findCachedRecord(app, baseID, tableID, recordID); cached != nil {
+		if !cached.GetBool("found") {
+			app.Logger().Info("Record cache negative hit", "recordID", recordID)
+			return e.NotFoundError("Record not found", nil)
+		}
+
+		app.Logger().Info("Record cache hit",
+			"recordID", recordID,
+			"larkRecordID", cached.GetString("lark_record_id"))
+
+		return e.Redirect(http.StatusFound, cached.GetString("shared_url"))
+	}
+
+	// 根据 table 配置构建查找条件
+	filter, err := buildRecordLookupFilter(table, recordID)
+	if err != nil {
+		app.Logger().Warn("Invalid record lookup value", "recordID", recordID, "error", err)
+		return e.BadRequestError("Invalid record id", err)
+	}
+
 	// 使用搜索记录的方式获取记录
 	searchReq := larkbitable.NewSearchAppTableRecordReqBuilder().
 		AppToken(baseID).
 		TableId(tableID).
 		PageSize(20).
 		Body(larkbitable.NewSearchAppTableRecordReqBodyBuilder().
-			Filter(larkbitable.NewFilterInfoBuilder().
-				Conjunction(`and`).
-				Conditions([]*larkbitable.Condition{
-					larkbitable.NewConditionBuilder().
-						FieldName(`编号`).
-						Operator(`is`).
-						Value([]string{recordID}).
-						Build(),
-				}).
-				Build()).
+			Filter(filter).
 			AutomaticFields(false).
 			Build()).
 		Build()
@@ -124,6 +139,7 @@ func LarkBaseTable(e *core.RequestEvent) error {
 	// 检查是否找到记录
 	if searchResp.Data == nil || len(searchResp.Data.Items) == 0 {
 		app.Logger().Warn("No record found with the given ID", "recordID", recordID)
+		storeCachedRecord(app, larkConfig.NegativeCacheTTL, baseID, tableID, recordID, "", "", false)
 		return e.NotFoundError("Record not found", nil)
 	}
 
@@ -158,6 +174,7 @@ func LarkBaseTable(e *core.RequestEvent) error {
 	// 检查是否获取到记录详情
 	if batchGetResp.Data == nil || len(batchGetResp.Data.Records) == 0 {
 		app.Logger().Warn("No record details found", "recordID", *record.RecordId)
+		storeCachedRecord(app, larkConfig.NegativeCacheTTL, baseID, tableID, recordID, *record.RecordId, "", false)
 		return e.NotFoundError("Record details not found", nil)
 	}
 
@@ -168,10 +185,70 @@ func LarkBaseTable(e *core.RequestEvent) error {
 		sharedURL = *recordDetail.SharedUrl
 	} else {
 		app.Logger().Warn("Shared URL not found in record details", "recordID", *record.RecordId)
+		storeCachedRecord(app, larkConfig.NegativeCacheTTL, baseID, tableID, recordID, *record.RecordId, "", false)
 		return e.NotFoundError("Shared URL not available", nil)
 	}
 
 	app.Logger().Info("Retrieved shared URL", "recordID", *record.RecordId, "sharedURL", sharedURL)
 
+	storeCachedRecord(app, larkConfig.CacheTTL, baseID, tableID, recordID, *record.RecordId, sharedURL, true)
+
 	return e.Redirect(http.StatusFound, sharedURL)
 }
+
+// 默认的记录查找配置
+const (
+	defaultLookupField    = "编号"
+	defaultLookupOperator = "is"
+)
+
+// buildRecordLookupFilter 根据 lark_table 中的查找配置构建搜索条件
+func buildRecordLookupFilter(table *core.Record, recordID string) (*larkbitable.FilterInfo, error) {
+	fieldName := table.GetString("lookup_field")
+	if fieldName == "" {
+		fieldName = defaultLookupField
+	}
+
+	operator := table.GetString("lookup_operator")
+	if operator == "" {
+		operator = defaultLookupOperator
+	}
+
+	value, err := coerceLookupValue(table.GetString("lookup_value_type"), recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	return larkbitable.NewFilterInfoBuilder().
+		Conjunction(`and`).
+		Conditions([]*larkbitable.Condition{
+			larkbitable.NewConditionBuilder().
+				FieldName(fieldName).
+				Operator(operator).
+				Value([]string{value}).
+				Build(),
+		}).
+		Build(), nil
+}
+
+// coerceLookupValue 按配置的类型转换查找值
+func coerceLookupValue(valueType, value string) (string, error) {
+	switch valueType {
+	case "", "text":
+		return value, nil
+	case "number":
+		// 规范化数字格式，例如 "007" -> "7"
+		// ParseFloat 也接受 NaN 和 Inf，它们无法构成有效的飞书搜索条件
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return "", fmt.Errorf("record id %q is not a finite number", value)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case "upper":
+		return strings.ToUpper(value), nil
+	case "lower":
+		return strings.ToLower(value), nil
+	default:
+		return "", fmt.Errorf("unsupported lookup value type %q", valueType)
+	}
+}
@@ -0,0 +1,147 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const larkRecordCacheCollection = "lark_record_cache"
+
+// findCachedRecord 查询未过期的记录解析缓存，未命中时返回 nil
+func findCachedRecord(app core.App, baseID, tableID, recordKey string) *core.Record {
+	record, err := app.FindFirstRecordByFilter(
+		larkRecordCacheCollection,
+		"base_id = {:baseID} && table_id = {:tableID} && record_key = {:recordKey} && expires_at > {:now}",
+		dbx.Params{
+			"baseID":    baseID,
+			"tableID":   tableID,
+			"recordKey": recordKey,
+			"now":       types.NowDateTime().String(),
+		},
+	)
+	if err != nil {
+		return nil
+	}
+	return record
+}
+
+// storeCachedRecord 写入记录解析缓存，ttl 不大于 0 时不缓存
+func storeCachedRecord(app core.App, ttl time.Duration, baseID, tableID, recordKey, larkRecordID, sharedURL string, found bool) {
+	if ttl <= 0 {
+		return
+	}
+
+	record, err := app.FindFirstRecordByFilter(
+		larkRecordCacheCollection,
+		"base_id = {:baseID} && table_id = {:tableID} && record_key = {:recordKey}",
+		dbx.Params{"baseID": baseID, "tableID": tableID, "recordKey": recordKey},
+	)
+	if err != nil {
+		collection, err := app.FindCachedCollectionByNameOrId(larkRecordCacheCollection)
+		if err != nil {
+			app.Logger().Warn("lark_record_cache collection not found", "error", err)
+			return
+		}
+		record = core.NewRecord(collection)
+		record.Set("base_id", baseID)
+		record.Set("table_id", tableID)
+		record.Set("record_key", recordKey)
+	}
+
+	record.Set("lark_record_id", larkRecordID)
+	record.Set("shared_url", sharedURL)
+	record.Set("found", found)
+	record.Set("expires_at", types.NowDateTime().Add(ttl))
+
+	if err := app.Save(record); err != nil {
+		app.Logger().Error("Failed to save record cache", "error", err, "recordKey", recordKey)
+	}
+}
+
+// purgeCachedRecords 删除满足条件的缓存记录，返回删除数量
+func purgeCachedRecords(app core.App, filter string, params dbx.Params) (int, error) {
+	records, err := app.FindRecordsByFilter(larkRecordCacheCollection, filter, "", 0, 0, params)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, record := range records {
+		if err := app.Delete(record); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(records), nil
+}
+
+// LarkRecordCachePurge 清除记录解析缓存
+//
+// 支持通过 base_id、table_id、record_id 查询参数缩小清除范围，均不提供时清除全部缓存。
+func LarkRecordCachePurge(e *core.RequestEvent) error {
+	app := e.App
+
+	filter := "id != ''"
+	params := dbx.Params{}
+
+	if baseID := e.Request.URL.Query().Get("base_id"); baseID != "" {
+		filter += " && base_id = {:baseID}"
+		params["baseID"] = baseID
+	}
+	if tableID := e.Request.URL.Query().Get("table_id"); tableID != "" {
+		filter += " && table_id = {:tableID}"
+		params["tableID"] = tableID
+	}
+	if recordID := e.Request.URL.Query().Get("record_id"); recordID != "" {
+		filter += " && record_key = {:recordKey}"
+		params["recordKey"] = recordID
+	}
+
+	deleted, err := purgeCachedRecords(app, filter, params)
+	if err != nil {
+		app.Logger().Error("Failed to purge record cache", "error", err)
+		return e.InternalServerError("Failed to purge record cache", err)
+	}
+
+	app.Logger().Info("Record cache purged", "filter", filter, "deleted", deleted)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Record cache purged",
+		"deleted": deleted,
+	})
+}
+
+// BindLarkRecordCacheHooks 注册缓存失效钩子和过期清理任务
+func BindLarkRecordCacheHooks(app core.App) {
+	// lark_table 的查找配置变化或删除时，清除该表的缓存
+	invalidateTable := func(e *core.RecordEvent) error {
+		tableID := e.Record.GetString("table_id")
+		if original := e.Record.Original(); original != nil && original.GetString("table_id") != tableID {
+			if _, err := purgeCachedRecords(e.App, "table_id = {:tableID}", dbx.Params{"tableID": original.GetString("table_id")}); err != nil {
+				e.App.Logger().Error("Failed to invalidate record cache", "error", err, "tableID", original.GetString("table_id"))
+			}
+		}
+		if _, err := purgeCachedRecords(e.App, "table_id = {:tableID}", dbx.Params{"tableID": tableID}); err != nil {
+			e.App.Logger().Error("Failed to invalidate record cache", "error", err, "tableID", tableID)
+		}
+		return e.Next()
+	}
+	app.OnRecordAfterUpdateSuccess("lark_table").BindFunc(invalidateTable)
+	app.OnRecordAfterDeleteSuccess("lark_table").BindFunc(invalidateTable)
+
+	// 每小时清理过期缓存
+	app.Cron().MustAdd("lark_record_cache_cleanup", "0 * * * *", func() {
+		deleted, err := purgeCachedRecords(app, "expires_at <= {:now}", dbx.Params{"now": types.NowDateTime().String()})
+		if err != nil {
+			app.Logger().Error("Failed to clean up expired record cache", "error", err)
+			return
+		}
+		if deleted > 0 {
+			app.Logger().Info("Expired record cache cleaned up", "deleted", deleted)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkdrive "github.com/larksuite/oapi-sdk-go/v3/service/drive/v1"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// LarkEvent 飞书事件回调请求体，包含 v2 事件和URL验证请求
+type LarkEvent struct {
+	Schema string          `json:"schema"`
+	Header LarkEventHeader `json:"header"`
+	Event  json.RawMessage `json:"event"`
+
+	// URL验证请求字段
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+}
+
+// LarkEventHeader 飞书 v2 事件头
+type LarkEventHeader struct {
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	CreateTime string `json:"create_time"`
+	Token      string `json:"token"`
+	AppID      string `json:"app_id"`
+	TenantKey  string `json:"tenant_key"`
+}
+
+// LarkWebhook 处理飞书事件订阅回调，签名校验、解密和 token 校验由 LarkEventVerify 中间件完成
+func LarkWebhook(e *core.RequestEvent) error {
+	app := e.App
+
+	// 读取请求体
+	body, err := io.ReadAll(e.Request.Body)
+	if err != nil {
+		app.Logger().Error("Failed to read request body", "error", err)
+		return e.BadRequestError("Failed to read request body", err)
+	}
+
+	var event LarkEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		app.Logger().Error("Failed to parse lark event", "error", err)
+		return e.BadRequestError("Invalid Lark event format", err)
+	}
+
+	// URL验证请求原样返回 challenge
+	if event.Type == "url_verification" {
+		app.Logger().Info("Lark event URL verification")
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"challenge": event.Challenge,
+		})
+	}
+
+	// 仅处理 v2 事件，v1 事件没有 schema 和 header
+	if event.Schema != "2.0" {
+		app.Logger().Info("Unsupported Lark event schema", "schema", event.Schema)
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "Event received but not processed",
+		})
+	}
+
+	app.Logger().Info("Processing Lark event",
+		"eventType", event.Header.EventType,
+		"eventID", event.Header.EventID,
+	)
+
+	// 根据事件类型处理
+	switch event.Header.EventType {
+	case "drive.file.bitable_record_changed_v1":
+		return handleBitableRecordChangedEvent(e, event)
+	default:
+		app.Logger().Info("Unsupported Lark event type", "eventType", event.Header.EventType)
+		return e.JSON(http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "Event received but not processed",
+			"event":   event.Header.EventType,
+		})
+	}
+}
+
+// handleBitableRecordChangedEvent 处理多维表格记录变更事件，将同步行上的修改回写到GitLab Issue
+func handleBitableRecordChangedEvent(e *core.RequestEvent, event LarkEvent) error {
+	app := e.App
+
+	var data larkdrive.P2FileBitableRecordChangedV1Data
+	if err := json.Unmarshal(event.Event, &data); err != nil {
+		app.Logger().Error("Failed to parse bitable record changed event", "error", err)
+		return e.BadRequestError("Invalid bitable record changed event format", err)
+	}
+
+	fileToken := larkcore.StringValue(data.FileToken)
+	tableID := larkcore.StringValue(data.TableId)
+
+	app.Logger().Info("Processing bitable record changed event",
+		"fileToken", fileToken,
+		"tableID", tableID,
+		"actions", len(data.ActionList),
+	)
+
+	larkClient, larkOK := larkClientFromRequest(e)
+	gitlabClient, gitlabOK := gitlabAPIClientFromRequest(e)
+	if !larkOK || !gitlabOK {
+		app.Logger().Warn("Lark app or GitLab API token not configured, skipping write-back",
+			"larkConfigured", larkOK,
+			"gitlabConfigured", gitlabOK,
+		)
+	} else {
+		var writebackErrs []error
+		for _, action := range data.ActionList {
+			if action == nil || larkcore.StringValue(action.Action) != "record_edited" {
+				continue
+			}
+
+			recordID := larkcore.StringValue(action.RecordId)
+			if err := writeBackIssueFromLark(app, larkClient, gitlabClient, fileToken, tableID, recordID); err != nil {
+				app.Logger().Error("Failed to write back lark record to gitlab",
+					"error", err,
+					"tableID", tableID,
+					"larkRecordID", recordID,
+				)
+				writebackErrs = append(writebackErrs, err)
+			}
+		}
+
+		// 回写失败时返回错误，事件在 lark_events 中标记为失败，飞书重试时重新处理
+		if len(writebackErrs) > 0 {
+			return e.InternalServerError("Failed to write back lark record to gitlab", errors.Join(writebackErrs...))
+		}
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Bitable record changed event processed",
+		"event": map[string]interface{}{
+			"file_token": fileToken,
+			"table_id":   tableID,
+			"actions":    len(data.ActionList),
+		},
+	})
+}
+
+// LarkBaseSubscribe 为多维表格订阅记录变更事件，飞书只推送已订阅文档的 drive.file.bitable_record_changed_v1 事件
+func LarkBaseSubscribe(e *core.RequestEvent) error {
+	app := e.App
+	baseID := e.Request.PathValue("baseID")
+
+	client, ok := larkClientFromRequest(e)
+	if !ok {
+		return e.BadRequestError("Missing Lark configuration", nil)
+	}
+
+	if _, err := app.FindFirstRecordByData("lark_base", "base_id", baseID); err != nil {
+		return e.NotFoundError("Base not found", err)
+	}
+
+	req := larkdrive.NewSubscribeFileReqBuilder().
+		FileToken(baseID).
+		FileType("bitable").
+		Build()
+
+	resp, err := client.Drive.V1.File.Subscribe(context.Background(), req)
+	if err != nil {
+		app.Logger().Error("Failed to subscribe base events", "error", err, "baseID", baseID)
+		return e.InternalServerError("Failed to subscribe base events", err)
+	}
+	if !resp.Success() {
+		app.Logger().Error("Lark API subscribe request failed", "code", resp.Code, "msg", resp.Msg, "requestId", resp.RequestId())
+		return e.InternalServerError("Failed to subscribe base events", fmt.Errorf("code: %d, msg: %s", resp.Code, resp.Msg))
+	}
+
+	app.Logger().Info("Base events subscribed", "baseID", baseID)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Base events subscribed",
+		"base_id": baseID,
+	})
+}
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"gitlab.yogorobot.com/sre/lark-base-mapping/middlewares"
+)
+
+// maxLarkLinkTTL 签名链接允许的最长有效期
+const maxLarkLinkTTL = 7 * 24 * time.Hour
+
+// LarkLinkRequest 生成签名链接的请求体
+type LarkLinkRequest struct {
+	BaseID     string `json:"base_id"`
+	TableID    string `json:"table_id"`
+	RecordID   string `json:"record_id"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// LarkSignedLink 为 access_policy 为 signed 的表生成短期签名链接
+//
+// 表不存在或不属于请求的 base 时返回 404，表的访问策略不是 signed 时返回 403。
+func LarkSignedLink(e *core.RequestEvent) error {
+	app := e.App
+
+	larkConfig, ok := middlewares.GetLarkConfigFromContext(e.Request.Context())
+	if !ok {
+		return e.BadRequestError("Lark config not found in context", nil)
+	}
+
+	if larkConfig.LinkSecret == "" {
+		return e.BadRequestError("Lark link secret not configured", nil)
+	}
+
+	var req LarkLinkRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.BadRequestError("Invalid request body", err)
+	}
+
+	if req.BaseID == "" || req.TableID == "" {
+		return e.BadRequestError("base_id and table_id are required", nil)
+	}
+
+	table, err := app.FindFirstRecordByData("lark_table", "table_id", req.TableID)
+	if err != nil {
+		return e.NotFoundError("Table not found", err)
+	}
+
+	base, err := app.FindRecordById("lark_base", table.GetString("base_id"))
+	if err != nil || base.GetString("base_id") != req.BaseID {
+		return e.NotFoundError("Table is not associated with the requested base", err)
+	}
+
+	if table.GetString("access_policy") != "signed" {
+		return e.ForbiddenError("Signed links are only available for tables with the signed access policy", nil)
+	}
+
+	ttl := larkConfig.LinkTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > maxLarkLinkTTL {
+		return e.BadRequestError(fmt.Sprintf("ttl must be between 1s and %s", maxLarkLinkTTL), nil)
+	}
+
+	signature, exp := middlewares.SignLarkLink(larkConfig.LinkSecret, req.BaseID, req.TableID, req.RecordID, time.Now().Add(ttl))
+
+	path := fmt.Sprintf("/base/%s/%s", url.PathEscape(req.BaseID), url.PathEscape(req.TableID))
+	if req.RecordID != "" {
+		path += "/" + url.PathEscape(req.RecordID)
+	}
+
+	query := url.Values{}
+	query.Set("exp", fmt.Sprint(exp))
+	query.Set("sig", signature)
+
+	link := strings.TrimRight(app.Settings().Meta.AppURL, "/") + path + "?" + query.Encode()
+
+	app.Logger().Info("Signed Lark link generated",
+		"baseID", req.BaseID,
+		"tableID", req.TableID,
+		"recordID", req.RecordID,
+		"expiresAt", exp,
+		"authRecordID", e.Auth.Id,
+	)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":     "success",
+		"url":        link,
+		"expires_at": time.Unix(exp, 0).UTC().Format(time.RFC3339),
+	})
+}
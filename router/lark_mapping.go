@@ -0,0 +1,494 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// lark_field_mappings 的数据来源
+const (
+	larkMappingSourceMergeRequest = "merge_request"
+	larkMappingSourceIssue        = "issue"
+)
+
+// larkRecordPathPrefix source_path 以此开头时从记录已保存的字段取值，例如 $record.pipeline_status，
+// 其余路径从记录的 event_data 取值
+const larkRecordPathPrefix = "$record"
+
+// larkFieldMapping lark_field_mappings 中的一条字段映射
+type larkFieldMapping struct {
+	SourcePath  string
+	TargetField string
+	FieldType   string
+	Transform   string
+	Options     larkFieldMappingOptions
+
+	directory  func(userID int, username string) string // user 转换：options.users 未配置时按 user_mappings 查询 open_id
+	fromRecord bool                                     // source_path 是否从记录已保存的字段取值
+	steps      []jsonPathStep
+	textSteps  []jsonPathStep
+}
+
+// larkFieldMappingOptions 字段映射的转换参数
+type larkFieldMappingOptions struct {
+	Users    map[string]string `json:"users"`     // user 转换：GitLab 用户名 -> 飞书 open_id，优先于 user_mappings
+	Labels   map[string]string `json:"labels"`    // label 转换：源值 -> 飞书选项名，未配置的值原样保留
+	Text     string            `json:"text"`      // url 转换：固定的链接文本
+	TextPath string            `json:"text_path"` // url 转换：链接文本的 JSONPath，优先于 text
+}
+
+// jsonPathStep JSONPath 中的一级访问：字段名、数组下标或通配符
+type jsonPathStep struct {
+	key      string
+	index    int
+	wildcard bool
+}
+
+// parseJSONPath 解析 JSONPath 子集：$.a.b、$.a[0]、$.a[-1]、$.a[*].b、$['a b']
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("json path %q must start with $", path)
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+
+			switch key {
+			case "":
+				return nil, fmt.Errorf("json path %q has an empty field name", path)
+			case "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			default:
+				steps = append(steps, jsonPathStep{key: key})
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("json path %q has an unclosed bracket", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(inner) > 2 && inner[0] == '\'' && inner[len(inner)-1] == '\'':
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("json path %q has an invalid index %q", path, inner)
+				}
+				steps = append(steps, jsonPathStep{index: index})
+			}
+		default:
+			return nil, fmt.Errorf("json path %q has an unexpected character %q", path, rest[0])
+		}
+	}
+
+	return steps, nil
+}
+
+// evalJSONPath 在解析后的JSON数据上取值，路径不存在时返回 nil，通配符返回数组
+func evalJSONPath(data interface{}, steps []jsonPathStep) interface{} {
+	for i, step := range steps {
+		switch {
+		case step.wildcard:
+			var items []interface{}
+			switch value := data.(type) {
+			case []interface{}:
+				items = value
+			case map[string]interface{}:
+				keys := make([]string, 0, len(value))
+				for key := range value {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					items = append(items, value[key])
+				}
+			default:
+				return nil
+			}
+
+			results := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				if value := evalJSONPath(item, steps[i+1:]); value != nil {
+					results = append(results, value)
+				}
+			}
+			return results
+		case step.key != "":
+			object, ok := data.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			data = object[step.key]
+		default:
+			array, ok := data.([]interface{})
+			if !ok {
+				return nil
+			}
+			index := step.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil
+			}
+			data = array[index]
+		}
+	}
+	return data
+}
+
+// loadLarkFieldMappings 读取 lark_table 指定来源的字段映射，路径无效的映射被跳过
+func loadLarkFieldMappings(app core.App, tableRecordID, source string) ([]larkFieldMapping, error) {
+	records, err := app.FindRecordsByFilter(
+		"lark_field_mappings",
+		"lark_table = {:table} && source = {:source}",
+		"target_field",
+		0,
+		0,
+		dbx.Params{"table": tableRecordID, "source": source},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := make([]larkFieldMapping, 0, len(records))
+	for _, record := range records {
+		mapping, err := newLarkFieldMapping(record)
+		if err != nil {
+			app.Logger().Warn("Invalid lark field mapping, skipping", "error", err, "mappingID", record.Id)
+			continue
+		}
+		mapping.directory = func(userID int, username string) string {
+			return lookupLarkOpenID(app, userID, username)
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
+
+// newLarkFieldMapping 从 lark_field_mappings 记录构造字段映射并解析其中的 JSONPath
+func newLarkFieldMapping(record *core.Record) (larkFieldMapping, error) {
+	mapping := larkFieldMapping{
+		SourcePath:  record.GetString("source_path"),
+		TargetField: record.GetString("target_field"),
+		FieldType:   record.GetString("field_type"),
+		Transform:   record.GetString("transform"),
+	}
+
+	if err := record.UnmarshalJSONField("options", &mapping.Options); err != nil {
+		return mapping, fmt.Errorf("invalid options: %w", err)
+	}
+
+	steps, fromRecord, err := parseSourcePath(mapping.SourcePath)
+	if err != nil {
+		return mapping, err
+	}
+	mapping.steps = steps
+	mapping.fromRecord = fromRecord
+
+	if mapping.Options.TextPath != "" {
+		if mapping.textSteps, err = parseJSONPath(mapping.Options.TextPath); err != nil {
+			return mapping, err
+		}
+	}
+
+	return mapping, nil
+}
+
+// parseSourcePath 解析 source_path，$record 开头的路径返回 fromRecord 为 true
+func parseSourcePath(path string) (steps []jsonPathStep, fromRecord bool, err error) {
+	if rest, ok := strings.CutPrefix(path, larkRecordPathPrefix); ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+		steps, err = parseJSONPath("$" + rest)
+		return steps, true, err
+	}
+	steps, err = parseJSONPath(path)
+	return steps, false, err
+}
+
+// buildLarkFields 按字段映射从记录的 event_data 或已保存的字段取值，构造飞书多维表格记录字段
+func buildLarkFields(record *core.Record, mappings []larkFieldMapping) (map[string]interface{}, error) {
+	var data interface{}
+	if err := record.UnmarshalJSONField("event_data", &data); err != nil {
+		return nil, fmt.Errorf("invalid event_data: %w", err)
+	}
+	stored := recordDocument(record)
+
+	fields := make(map[string]interface{}, len(mappings))
+	for _, mapping := range mappings {
+		source := data
+		if mapping.fromRecord {
+			source = stored
+		}
+		value := mapping.transform(data, evalJSONPath(source, mapping.steps))
+		fields[mapping.TargetField] = coerceLarkFieldValue(mapping.FieldType, value)
+	}
+	return fields, nil
+}
+
+// recordDocument 将记录已保存的字段转换为可按 JSONPath 取值的文档，JSON 字段解析为对应的值
+func recordDocument(record *core.Record) map[string]interface{} {
+	document := map[string]interface{}{"id": record.Id}
+	for _, field := range record.Collection().Fields {
+		value := record.Get(field.GetName())
+		if raw, ok := value.(types.JSONRaw); ok {
+			var decoded interface{}
+			if err := json.Unmarshal(raw, &decoded); err != nil {
+				continue
+			}
+			value = decoded
+		}
+		document[field.GetName()] = value
+	}
+	return document
+}
+
+// transform 按映射配置的转换处理取到的值，未配置转换的 user、url 字段按字段类型转换
+func (m larkFieldMapping) transform(data, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	transform := m.Transform
+	if transform == "" && (m.FieldType == "user" || m.FieldType == "url") {
+		transform = m.FieldType
+	}
+
+	switch transform {
+	case "date":
+		return toLarkTimestamp(value)
+	case "user":
+		return m.lookupUsers(value)
+	case "url":
+		link := stringifyValue(value)
+		if link == "" {
+			return nil
+		}
+		text := m.Options.Text
+		if m.textSteps != nil {
+			text = stringifyValue(evalJSONPath(data, m.textSteps))
+		}
+		if text == "" {
+			text = link
+		}
+		return map[string]interface{}{"link": link, "text": text}
+	case "label":
+		if items, ok := value.([]interface{}); ok {
+			labels := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				labels = append(labels, m.mapLabel(item))
+			}
+			return labels
+		}
+		return m.mapLabel(value)
+	default:
+		return value
+	}
+}
+
+// mapLabel 将单个源值映射为飞书选项名
+func (m larkFieldMapping) mapLabel(value interface{}) interface{} {
+	if label, ok := m.Options.Labels[stringifyValue(value)]; ok {
+		return label
+	}
+	return value
+}
+
+// lookupUsers 将 GitLab 用户（用户名或含 username 的对象，可为数组）转换为飞书人员字段，
+// 按 options.users、user_mappings 的顺序查找 open_id，对象中带 id 时 user_mappings 优先按用户ID查找，均未映射的用户被忽略
+func (m larkFieldMapping) lookupUsers(value interface{}) interface{} {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+
+	users := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		username := ""
+		userID := 0
+		switch user := item.(type) {
+		case string:
+			username = user
+		case map[string]interface{}:
+			username, _ = user["username"].(string)
+			if id, ok := user["id"].(float64); ok {
+				userID = int(id)
+			}
+		}
+
+		openID := m.Options.Users[username]
+		if openID == "" && (username != "" || userID > 0) && m.directory != nil {
+			openID = m.directory(userID, username)
+		}
+		if openID != "" {
+			users = append(users, map[string]string{"id": openID})
+		}
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+	return users
+}
+
+// coerceLarkFieldValue 按飞书字段类型规范化写入值
+func coerceLarkFieldValue(fieldType string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch fieldType {
+	case "text":
+		return stringifyValue(value)
+	case "number":
+		switch v := value.(type) {
+		case float64:
+			return v
+		case int64:
+			return float64(v)
+		case string:
+			if number, err := strconv.ParseFloat(v, 64); err == nil {
+				return number
+			}
+		}
+		return nil
+	case "single_select":
+		if items, ok := value.([]interface{}); ok {
+			if len(items) == 0 {
+				return nil
+			}
+			value = items[0]
+		}
+		return stringifyValue(value)
+	case "multi_select":
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		options := make([]string, 0, len(items))
+		for _, item := range items {
+			if option := stringifyValue(item); option != "" {
+				options = append(options, option)
+			}
+		}
+		return options
+	case "checkbox":
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			return v == "true"
+		case float64:
+			return v != 0
+		}
+		return false
+	case "date":
+		return toLarkTimestamp(value)
+	case "user":
+		// 人员字段格式为 [{"id": open_id}]，由 user 转换生成
+		if users, ok := value.([]map[string]string); ok && len(users) > 0 {
+			return users
+		}
+		return nil
+	case "url":
+		// 超链接字段格式为 {"link": ..., "text": ...}，由 url 转换生成
+		if link, ok := value.(map[string]interface{}); ok && link["link"] != nil {
+			return link
+		}
+		return nil
+	default:
+		return value
+	}
+}
+
+// toLarkTimestamp 将 GitLab 时间转换为飞书日期字段使用的毫秒时间戳，数字视为已是毫秒时间戳
+func toLarkTimestamp(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64, int64:
+		return v
+	case string:
+		raw, _ := json.Marshal(v)
+		var t FlexibleTime
+		if err := json.Unmarshal(raw, &t); err != nil || t.IsZero() {
+			return nil
+		}
+		return t.UnixMilli()
+	case types.DateTime:
+		if v.IsZero() {
+			return nil
+		}
+		return v.Time().UnixMilli()
+	}
+	return nil
+}
+
+// stringifyValue 将JSON值转换为文本，数组以逗号连接
+func stringifyValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case types.DateTime:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if part := stringifyValue(item); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+}
+
+// BindLarkFieldMappingHooks 注册字段映射的校验钩子，保存前检查 JSONPath 是否有效
+func BindLarkFieldMappingHooks(app core.App) {
+	app.OnRecordValidate("lark_field_mappings").BindFunc(func(e *core.RecordEvent) error {
+		if _, _, err := parseSourcePath(e.Record.GetString("source_path")); err != nil {
+			return validation.Errors{"source_path": validation.NewError("validation_invalid_json_path", err.Error())}
+		}
+
+		var options larkFieldMappingOptions
+		if err := e.Record.UnmarshalJSONField("options", &options); err != nil {
+			return validation.Errors{"options": validation.NewError("validation_invalid_options", err.Error())}
+		}
+		if options.TextPath != "" {
+			if _, err := parseJSONPath(options.TextPath); err != nil {
+				return validation.Errors{"options": validation.NewError("validation_invalid_json_path", err.Error())}
+			}
+		}
+
+		return e.Next()
+	})
+}
@@ -0,0 +1,350 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// mergeRequestStateLabels MR状态在卡片中的显示文本和标题颜色
+var mergeRequestStateLabels = map[string][2]string{
+	"opened": {"进行中", "blue"},
+	"merged": {"已合并", "green"},
+	"closed": {"已关闭", "grey"},
+	"locked": {"已锁定", "grey"},
+}
+
+// pipelineStatusLabels 流水线状态在卡片中的显示文本
+var pipelineStatusLabels = map[string]string{
+	"created":              "已创建",
+	"waiting_for_resource": "等待资源",
+	"preparing":            "准备中",
+	"pending":              "等待中",
+	"running":              "运行中",
+	"success":              "✅ 成功",
+	"failed":               "❌ 失败",
+	"canceled":             "已取消",
+	"skipped":              "已跳过",
+	"manual":               "待手动触发",
+	"scheduled":            "已计划",
+}
+
+// notifyMergeRequestCard 在项目配置的飞书群中发送或更新MR卡片
+//
+// MR打开、合并、关闭或存在阻塞讨论时发送卡片，并把消息ID写入 lark_card_message_id；
+// 已发送过卡片的MR之后的事件原地更新同一张卡片。通知失败只记录日志，不影响webhook处理结果。
+func notifyMergeRequestCard(app core.App, client *lark.Client, record *core.Record) {
+	if record.GetString("lark_card_message_id") != "" {
+		refreshMergeRequestCard(app, client, record)
+		return
+	}
+
+	if !shouldSendMergeRequestCard(record) {
+		return
+	}
+
+	chatID := findProjectChatID(app, record.GetInt("project_id"))
+	if chatID == "" {
+		return
+	}
+
+	card, err := buildMergeRequestCard(record)
+	if err != nil {
+		app.Logger().Error("Failed to build merge request card", "error", err, "recordID", record.Id)
+		return
+	}
+
+	req := larkim.NewCreateMessageReqBuilder().
+		ReceiveIdType(larkim.ReceiveIdTypeChatId).
+		Body(larkim.NewCreateMessageReqBodyBuilder().
+			ReceiveId(chatID).
+			MsgType(larkim.MsgTypeInteractive).
+			Content(card).
+			// 飞书按 uuid 对一小时内的重复发送去重，避免并发投递发出多张卡片
+			Uuid(fmt.Sprintf("gitlab-mr-%d-%d", record.GetInt("project_id"), record.GetInt("mr_iid"))).
+			Build()).
+		Build()
+
+	resp, err := client.Im.V1.Message.Create(context.Background(), req)
+	if err != nil {
+		app.Logger().Error("Failed to send merge request card", "error", err, "chatID", chatID, "recordID", record.Id)
+		return
+	}
+	if !resp.Success() {
+		app.Logger().Error("Lark API send message request failed", "code", resp.Code, "msg", resp.Msg, "requestId", resp.RequestId())
+		return
+	}
+
+	messageID := larkcore.StringValue(resp.Data.MessageId)
+	app.Logger().Info("Merge request card sent",
+		"projectID", record.GetInt("project_id"),
+		"mrIID", record.GetInt("mr_iid"),
+		"chatID", chatID,
+		"messageID", messageID,
+	)
+
+	record.Set("lark_card_message_id", messageID)
+	if err := app.Save(record); err != nil {
+		app.Logger().Error("Failed to save lark card message id", "error", err, "recordID", record.Id)
+	}
+}
+
+// refreshMergeRequestCard 原地更新已发送的MR卡片，未发送过卡片的MR不处理
+func refreshMergeRequestCard(app core.App, client *lark.Client, record *core.Record) {
+	messageID := record.GetString("lark_card_message_id")
+	if messageID == "" {
+		return
+	}
+
+	card, err := buildMergeRequestCard(record)
+	if err != nil {
+		app.Logger().Error("Failed to build merge request card", "error", err, "recordID", record.Id)
+		return
+	}
+
+	req := larkim.NewPatchMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewPatchMessageReqBodyBuilder().
+			Content(card).
+			Build()).
+		Build()
+
+	resp, err := client.Im.V1.Message.Patch(context.Background(), req)
+	if err != nil {
+		app.Logger().Error("Failed to update merge request card", "error", err, "messageID", messageID, "recordID", record.Id)
+		return
+	}
+	if !resp.Success() {
+		app.Logger().Error("Lark API patch message request failed", "code", resp.Code, "msg", resp.Msg, "requestId", resp.RequestId())
+		return
+	}
+
+	app.Logger().Info("Merge request card updated",
+		"projectID", record.GetInt("project_id"),
+		"mrIID", record.GetInt("mr_iid"),
+		"messageID", messageID,
+	)
+}
+
+// updateMergeRequestBlocking 按评论事件中MR的阻塞讨论状态更新MR记录，状态变化时发送或更新飞书卡片
+func updateMergeRequestBlocking(e *core.RequestEvent, projectID int, mr *MergeRequest) {
+	app := e.App
+
+	if mr.BlockingDiscussionsResolved == nil {
+		return
+	}
+
+	record, err := app.FindFirstRecordByFilter(
+		"gitlab_merge_requests",
+		"project_id = {:projectID} && mr_iid = {:iid}",
+		dbx.Params{"projectID": projectID, "iid": mr.IID},
+	)
+	if err != nil {
+		return
+	}
+
+	blocking := !*mr.BlockingDiscussionsResolved
+	if record.GetBool("has_blocking_discussions") == blocking {
+		return
+	}
+
+	record.Set("has_blocking_discussions", blocking)
+	if err := app.Save(record); err != nil {
+		app.Logger().Error("Failed to update merge request blocking discussions", "error", err, "recordID", record.Id)
+		return
+	}
+
+	app.Logger().Info("Merge request blocking discussions changed",
+		"projectID", projectID,
+		"mrIID", mr.IID,
+		"blocking", blocking,
+	)
+
+	if client, ok := larkClientFromRequest(e); ok {
+		notifyMergeRequestCard(app, client, record)
+	}
+}
+
+// notifyMergeRequestParticipants 向MR新增的评审人和指派人发送飞书私信，飞书身份按GitLab用户ID和用户名在 user_mappings 查询
+//
+// 同时被添加为评审人和指派人的用户只收到评审请求，操作人本人和未映射飞书身份的用户被跳过。通知失败只记录日志。
+func notifyMergeRequestParticipants(app core.App, client *lark.Client, actor User, mr mergeRequestState, reviewers, assignees []User) {
+	notified := map[string]bool{actor.Username: true}
+
+	notify := func(users []User, verb string) {
+		for _, user := range users {
+			if user.Username == "" || notified[user.Username] {
+				continue
+			}
+			notified[user.Username] = true
+
+			openID := lookupLarkOpenID(app, user.ID, user.Username)
+			if openID == "" {
+				app.Logger().Debug("GitLab user has no lark mapping, skipping direct message", "username", user.Username)
+				continue
+			}
+
+			text := fmt.Sprintf("%s %s %s!%d：%s\n%s", actor.Name, verb, mr.ProjectName, mr.IID, mr.Title, mr.URL)
+			if err := sendLarkText(context.Background(), client, larkim.ReceiveIdTypeOpenId, openID, text); err != nil {
+				app.Logger().Error("Failed to send merge request direct message",
+					"error", err,
+					"username", user.Username,
+					"projectID", mr.ProjectID,
+					"mrIID", mr.IID,
+				)
+				continue
+			}
+
+			app.Logger().Info("Merge request direct message sent",
+				"username", user.Username,
+				"projectID", mr.ProjectID,
+				"mrIID", mr.IID,
+			)
+		}
+	}
+
+	notify(reviewers, "请你评审合并请求")
+	notify(assignees, "将合并请求指派给你")
+}
+
+// addedUsers 返回 current 中新增（不在 previous 中）的用户
+func addedUsers(previous, current []User) []User {
+	existing := make(map[int]bool, len(previous))
+	for _, user := range previous {
+		existing[user.ID] = true
+	}
+
+	var added []User
+	for _, user := range current {
+		if !existing[user.ID] {
+			added = append(added, user)
+		}
+	}
+	return added
+}
+
+// shouldSendMergeRequestCard MR打开、合并、关闭或存在阻塞讨论时需要发送卡片
+func shouldSendMergeRequestCard(record *core.Record) bool {
+	switch record.GetString("state") {
+	case "merged", "closed":
+		return true
+	case "opened":
+		action := record.GetString("action")
+		return action == "open" || action == "reopen" || record.GetBool("has_blocking_discussions")
+	}
+	return false
+}
+
+// findProjectChatID 查询项目启用的通知群，未配置时返回空字符串
+func findProjectChatID(app core.App, projectID int) string {
+	chat, err := app.FindFirstRecordByFilter(
+		"gitlab_project_chats",
+		"project_id = {:projectID} && enabled = true",
+		dbx.Params{"projectID": projectID},
+	)
+	if err != nil {
+		return ""
+	}
+	return chat.GetString("chat_id")
+}
+
+// buildMergeRequestCard 按MR当前状态构造飞书消息卡片JSON
+func buildMergeRequestCard(record *core.Record) (string, error) {
+	state := record.GetString("state")
+	stateLabel, ok := mergeRequestStateLabels[state]
+	if !ok {
+		stateLabel = [2]string{state, "blue"}
+	}
+	template := stateLabel[1]
+
+	pipeline := pipelineStatusLabels[record.GetString("pipeline_status")]
+	if pipeline == "" {
+		pipeline = record.GetString("pipeline_status")
+	}
+	if pipeline == "" {
+		pipeline = "暂无"
+	}
+
+	author := record.GetString("author_name")
+	if username := record.GetString("author_username"); username != "" {
+		author = fmt.Sprintf("%s (@%s)", author, username)
+	}
+
+	field := func(name, value string) map[string]interface{} {
+		return map[string]interface{}{
+			"is_short": true,
+			"text": map[string]interface{}{
+				"tag":     "lark_md",
+				"content": fmt.Sprintf("**%s**\n%s", name, value),
+			},
+		}
+	}
+
+	elements := []interface{}{
+		map[string]interface{}{
+			"tag": "div",
+			"fields": []interface{}{
+				field("项目", record.GetString("project_name")),
+				field("作者", author),
+				field("分支", fmt.Sprintf("%s → %s", record.GetString("source_branch"), record.GetString("target_branch"))),
+				field("状态", stateLabel[0]),
+				field("流水线", pipeline),
+			},
+		},
+	}
+
+	if state == "opened" && record.GetBool("has_blocking_discussions") {
+		template = "orange"
+		elements = append(elements, map[string]interface{}{
+			"tag": "div",
+			"text": map[string]interface{}{
+				"tag":     "lark_md",
+				"content": "⚠️ 存在未解决的阻塞讨论，解决后才能合并",
+			},
+		})
+	}
+
+	if url := record.GetString("url"); url != "" {
+		button := func(text, url, buttonType string) map[string]interface{} {
+			return map[string]interface{}{
+				"tag":  "button",
+				"text": map[string]interface{}{"tag": "plain_text", "content": text},
+				"url":  url,
+				"type": buttonType,
+			}
+		}
+		elements = append(elements, map[string]interface{}{
+			"tag": "action",
+			"actions": []interface{}{
+				button("查看MR", url, "primary"),
+				button("查看变更", url+"/diffs", "default"),
+				button("查看流水线", url+"/pipelines", "default"),
+			},
+		})
+	}
+
+	card := map[string]interface{}{
+		// update_multi 为共享卡片，群内所有人看到同一张卡片，支持原地更新
+		"config": map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		"header": map[string]interface{}{
+			"template": template,
+			"title": map[string]interface{}{
+				"tag":     "plain_text",
+				"content": fmt.Sprintf("!%d %s", record.GetInt("mr_iid"), record.GetString("title")),
+			},
+		},
+		"elements": elements,
+	}
+
+	content, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
@@ -0,0 +1,242 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"gitlab.yogorobot.com/sre/lark-base-mapping/middlewares"
+)
+
+// larkRecordNotFoundCode 飞书多维表格记录不存在（RecordIdNotFound）的错误码
+const larkRecordNotFoundCode = 1254043
+
+// larkClientFromRequest 从请求上下文中获取已配置的飞书客户端，未配置飞书应用时返回 false
+func larkClientFromRequest(e *core.RequestEvent) (*lark.Client, bool) {
+	config, ok := middlewares.GetLarkConfigFromContext(e.Request.Context())
+	if !ok || config.AppID == "" || config.AppSecret == "" {
+		return nil, false
+	}
+	return middlewares.GetLarkClientFromContext(e.Request.Context())
+}
+
+// syncRecordToLark 将MR或Issue当前状态记录同步到 gitlab_project_id 匹配的飞书多维表格
+//
+// 每个 lark_table 按 lark_field_mappings 中对应来源（merge_request 或 issue）的映射构造记录字段，
+// 首次同步创建记录并把飞书 record_id 写入记录的 lark_record_ids，之后的事件更新同一条记录。
+// 机密Issue只同步到开启了 sync_confidential_issues 的表格，转为机密前已同步的飞书记录会被删除。
+// 同步失败只记录日志，不影响webhook处理结果。
+func syncRecordToLark(app core.App, client *lark.Client, record *core.Record, source string) {
+	tables, err := app.FindRecordsByFilter(
+		"lark_table",
+		"gitlab_project_id = {:projectID}",
+		"",
+		0,
+		0,
+		dbx.Params{"projectID": strconv.Itoa(record.GetInt("project_id"))},
+	)
+	if err != nil {
+		app.Logger().Error("Failed to load lark tables for sync", "error", err, "source", source)
+		return
+	}
+	if len(tables) == 0 {
+		return
+	}
+
+	recordIDs := map[string]string{}
+	if err := record.UnmarshalJSONField("lark_record_ids", &recordIDs); err != nil || recordIDs == nil {
+		recordIDs = map[string]string{}
+	}
+
+	// Issue 保存写入飞书的字段值，供飞书回写时识别同步自身的写入
+	trackFields := source == larkMappingSourceIssue
+	syncedFields := map[string]map[string]interface{}{}
+	if trackFields {
+		if err := record.UnmarshalJSONField("lark_synced_fields", &syncedFields); err != nil || syncedFields == nil {
+			syncedFields = map[string]map[string]interface{}{}
+		}
+	}
+
+	confidential := source == larkMappingSourceIssue && record.GetBool("confidential")
+
+	changed := false
+	for _, table := range tables {
+		if confidential && !table.GetBool("sync_confidential_issues") {
+			if removeConfidentialLarkRecord(app, client, record, table, recordIDs[table.Id]) {
+				delete(recordIDs, table.Id)
+				delete(syncedFields, table.Id)
+				changed = true
+			}
+			continue
+		}
+
+		mappings, err := loadLarkFieldMappings(app, table.Id, source)
+		if err != nil {
+			app.Logger().Error("Failed to load lark field mappings", "error", err, "tableID", table.GetString("table_id"))
+			continue
+		}
+		if len(mappings) == 0 {
+			app.Logger().Debug("Lark table has no field mapping for source, skipping",
+				"tableID", table.GetString("table_id"),
+				"source", source)
+			continue
+		}
+
+		base, err := app.FindRecordById("lark_base", table.GetString("base_id"))
+		if err != nil {
+			app.Logger().Error("Failed to find lark base for table", "error", err, "tableID", table.GetString("table_id"))
+			continue
+		}
+
+		appToken := base.GetString("base_id")
+		tableID := table.GetString("table_id")
+
+		fields, err := buildLarkFields(record, mappings)
+		if err != nil {
+			app.Logger().Error("Failed to build lark fields", "error", err, "recordID", record.Id, "tableID", tableID)
+			continue
+		}
+
+		larkRecordID, err := upsertLarkRecord(client, appToken, tableID, recordIDs[table.Id], fields)
+		if err != nil {
+			app.Logger().Error("Failed to sync record to lark",
+				"error", err,
+				"source", source,
+				"recordID", record.Id,
+				"projectID", record.GetInt("project_id"),
+				"tableID", tableID,
+			)
+			continue
+		}
+
+		app.Logger().Info("Record synced to lark",
+			"source", source,
+			"recordID", record.Id,
+			"projectID", record.GetInt("project_id"),
+			"tableID", tableID,
+			"larkRecordID", larkRecordID,
+		)
+
+		if recordIDs[table.Id] != larkRecordID {
+			recordIDs[table.Id] = larkRecordID
+			changed = true
+		}
+		if trackFields {
+			syncedFields[table.Id] = fields
+			changed = true
+		}
+	}
+
+	if changed {
+		record.Set("lark_record_ids", recordIDs)
+		if trackFields {
+			record.Set("lark_synced_fields", syncedFields)
+		}
+		if err := app.Save(record); err != nil {
+			app.Logger().Error("Failed to save lark record ids", "error", err, "recordID", record.Id)
+		}
+	}
+}
+
+// removeConfidentialLarkRecord 删除机密Issue在未开启机密同步的表格中已存在的飞书记录，返回是否已不再关联飞书记录
+func removeConfidentialLarkRecord(app core.App, client *lark.Client, record, table *core.Record, larkRecordID string) bool {
+	tableID := table.GetString("table_id")
+	if larkRecordID == "" {
+		app.Logger().Debug("Confidential issue not synced to lark table", "recordID", record.Id, "tableID", tableID)
+		return false
+	}
+
+	base, err := app.FindRecordById("lark_base", table.GetString("base_id"))
+	if err != nil {
+		app.Logger().Error("Failed to find lark base for table", "error", err, "tableID", tableID)
+		return false
+	}
+
+	if err := deleteLarkRecord(client, base.GetString("base_id"), tableID, larkRecordID); err != nil {
+		app.Logger().Error("Failed to delete confidential issue from lark",
+			"error", err,
+			"recordID", record.Id,
+			"tableID", tableID,
+			"larkRecordID", larkRecordID,
+		)
+		return false
+	}
+
+	app.Logger().Info("Confidential issue removed from lark",
+		"recordID", record.Id,
+		"projectID", record.GetInt("project_id"),
+		"tableID", tableID,
+		"larkRecordID", larkRecordID,
+	)
+	return true
+}
+
+// deleteLarkRecord 删除飞书记录，记录已不存在时视为成功
+func deleteLarkRecord(client *lark.Client, appToken, tableID, larkRecordID string) error {
+	req := larkbitable.NewDeleteAppTableRecordReqBuilder().
+		AppToken(appToken).
+		TableId(tableID).
+		RecordId(larkRecordID).
+		Build()
+
+	resp, err := client.Bitable.V1.AppTableRecord.Delete(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if !resp.Success() && resp.Code != larkRecordNotFoundCode {
+		return fmt.Errorf("delete record failed, code: %d, msg: %s, requestId: %s", resp.Code, resp.Msg, resp.RequestId())
+	}
+	return nil
+}
+
+// upsertLarkRecord 更新已有的飞书记录，记录不存在（如被手动删除）或尚未创建时新建，返回飞书 record_id
+func upsertLarkRecord(client *lark.Client, appToken, tableID, larkRecordID string, fields map[string]interface{}) (string, error) {
+	ctx := context.Background()
+
+	if larkRecordID != "" {
+		req := larkbitable.NewUpdateAppTableRecordReqBuilder().
+			AppToken(appToken).
+			TableId(tableID).
+			RecordId(larkRecordID).
+			AppTableRecord(larkbitable.NewAppTableRecordBuilder().
+				Fields(fields).
+				Build()).
+			Build()
+
+		resp, err := client.Bitable.V1.AppTableRecord.Update(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		if resp.Success() {
+			return larkRecordID, nil
+		}
+		if resp.Code != larkRecordNotFoundCode {
+			return "", fmt.Errorf("update record failed, code: %d, msg: %s, requestId: %s", resp.Code, resp.Msg, resp.RequestId())
+		}
+	}
+
+	req := larkbitable.NewCreateAppTableRecordReqBuilder().
+		AppToken(appToken).
+		TableId(tableID).
+		AppTableRecord(larkbitable.NewAppTableRecordBuilder().
+			Fields(fields).
+			Build()).
+		Build()
+
+	resp, err := client.Bitable.V1.AppTableRecord.Create(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success() {
+		return "", fmt.Errorf("create record failed, code: %d, msg: %s, requestId: %s", resp.Code, resp.Msg, resp.RequestId())
+	}
+	if resp.Data == nil || resp.Data.Record == nil || resp.Data.Record.RecordId == nil {
+		return "", fmt.Errorf("create record returned no record id")
+	}
+
+	return *resp.Data.Record.RecordId, nil
+}
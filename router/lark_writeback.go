@@ -0,0 +1,316 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// issueWritebackFields 可从飞书回写到GitLab Issue的字段映射，按映射的 source_path 识别对应的Issue属性
+var issueWritebackFields = map[string]string{
+	"$.object_attributes.state":           "state",
+	"$.assignees":                         "assignees",
+	"$.labels[*].title":                   "labels",
+	"$.object_attributes.labels[*].title": "labels",
+}
+
+// writeBackIssueFromLark 将飞书同步行上的状态、负责人和标签修改回写到关联的GitLab Issue
+//
+// 防止回环：同步写入飞书时在Issue的 lark_synced_fields 中保存写入的值，
+// 飞书当前值与其相同的字段视为同步自身的写入（或未修改），不会回写；
+// 与GitLab当前状态相同的修改也不会调用API。
+func writeBackIssueFromLark(app core.App, larkClient *lark.Client, gitlabClient *gitlabAPIClient, appToken, tableID, larkRecordID string) error {
+	table := findSyncedLarkTable(app, appToken, tableID)
+	if table == nil {
+		app.Logger().Debug("Bitable is not a synced lark table, skipping write-back", "tableID", tableID)
+		return nil
+	}
+
+	mappings, err := loadLarkFieldMappings(app, table.Id, larkMappingSourceIssue)
+	if err != nil {
+		return err
+	}
+
+	writebacks := make([]larkFieldMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		if issueWritebackFields[mapping.SourcePath] != "" {
+			writebacks = append(writebacks, mapping)
+		}
+	}
+	if len(writebacks) == 0 {
+		return nil
+	}
+
+	issue := findIssueByLarkRecord(app, table.Id, larkRecordID)
+	if issue == nil {
+		app.Logger().Debug("Lark record is not synced from an issue, skipping write-back", "larkRecordID", larkRecordID)
+		return nil
+	}
+
+	larkFields, err := getLarkRecordFields(larkClient, appToken, tableID, larkRecordID)
+	if err != nil {
+		return err
+	}
+
+	synced := map[string]map[string]interface{}{}
+	if err := issue.UnmarshalJSONField("lark_synced_fields", &synced); err != nil || synced == nil {
+		synced = map[string]map[string]interface{}{}
+	}
+	lastWritten := synced[table.Id]
+	if lastWritten == nil {
+		lastWritten = map[string]interface{}{}
+	}
+
+	ctx := context.Background()
+	params := map[string]interface{}{}
+	var pushedFields []string
+
+	for _, mapping := range writebacks {
+		current := normalizeLarkValue(larkFields[mapping.TargetField])
+
+		// 与上次同步写入的值相同，说明是同步自身的写入或该字段未被修改
+		if slices.Equal(current, normalizeLarkValue(lastWritten[mapping.TargetField])) {
+			continue
+		}
+
+		switch issueWritebackFields[mapping.SourcePath] {
+		case "state":
+			if len(current) == 0 {
+				continue
+			}
+			state := mapping.reverseLabel(current[0])
+			switch {
+			case state == "closed" && issue.GetString("state") != "closed":
+				params["state_event"] = "close"
+			case state == "opened" && issue.GetString("state") == "closed":
+				params["state_event"] = "reopen"
+			case state != "closed" && state != "opened":
+				app.Logger().Warn("Unknown issue state from lark, skipping", "value", current[0], "targetField", mapping.TargetField)
+				continue
+			}
+		case "labels":
+			labels := make([]string, 0, len(current))
+			for _, value := range current {
+				labels = append(labels, mapping.reverseLabel(value))
+			}
+			sort.Strings(labels)
+
+			var stored []string
+			_ = issue.UnmarshalJSONField("labels", &stored)
+			sort.Strings(stored)
+
+			if !slices.Equal(labels, stored) {
+				params["labels"] = strings.Join(labels, ",")
+			}
+		case "assignees":
+			ids, err := resolveGitLabUserIDs(ctx, app, gitlabClient, issue, mapping, current)
+			if err != nil {
+				app.Logger().Warn("Failed to resolve assignees from lark, skipping", "error", err, "targetField", mapping.TargetField)
+				continue
+			}
+
+			var stored []int
+			_ = issue.UnmarshalJSONField("assignee_ids", &stored)
+			sort.Ints(ids)
+			sort.Ints(stored)
+
+			if !slices.Equal(ids, stored) {
+				if len(ids) == 0 {
+					// GitLab 使用 0 表示取消全部指派
+					ids = []int{0}
+				}
+				params["assignee_ids"] = ids
+			}
+		}
+
+		pushedFields = append(pushedFields, mapping.TargetField)
+	}
+
+	if len(params) == 0 {
+		app.Logger().Debug("No issue changes to write back", "larkRecordID", larkRecordID, "issueIID", issue.GetInt("iid"))
+		return nil
+	}
+
+	if err := gitlabClient.UpdateIssue(ctx, issue.GetInt("project_id"), issue.GetInt("iid"), params); err != nil {
+		return err
+	}
+
+	app.Logger().Info("Lark record changes written back to issue",
+		"projectID", issue.GetInt("project_id"),
+		"issueIID", issue.GetInt("iid"),
+		"larkRecordID", larkRecordID,
+		"params", params,
+	)
+
+	// 记录已回写的飞书值，GitLab 回调同步前的再次修改仍能识别为新的修改
+	for _, field := range pushedFields {
+		lastWritten[field] = larkFields[field]
+	}
+	synced[table.Id] = lastWritten
+	issue.Set("lark_synced_fields", synced)
+	if err := app.Save(issue); err != nil {
+		// 未记录回写的值时，下次同步会把回写结果误判为新的修改，返回错误让事件标记为失败并由飞书重试
+		return fmt.Errorf("issue %s written back but failed to save lark synced fields: %w", issue.Id, err)
+	}
+
+	return nil
+}
+
+// findSyncedLarkTable 按飞书 app_token 和 table_id 查询配置了GitLab项目的 lark_table，未找到时返回 nil
+func findSyncedLarkTable(app core.App, appToken, tableID string) *core.Record {
+	tables, err := app.FindRecordsByFilter(
+		"lark_table",
+		"table_id = {:tableID} && gitlab_project_id != ''",
+		"",
+		0,
+		0,
+		dbx.Params{"tableID": tableID},
+	)
+	if err != nil {
+		return nil
+	}
+
+	for _, table := range tables {
+		base, err := app.FindRecordById("lark_base", table.GetString("base_id"))
+		if err == nil && base.GetString("base_id") == appToken {
+			return table
+		}
+	}
+	return nil
+}
+
+// findIssueByLarkRecord 查询同步到指定飞书记录的Issue，未找到时返回 nil
+func findIssueByLarkRecord(app core.App, tableRecordID, larkRecordID string) *core.Record {
+	issues, err := app.FindRecordsByFilter(
+		"gitlab_issues",
+		"lark_record_ids ~ {:larkRecordID}",
+		"",
+		0,
+		0,
+		dbx.Params{"larkRecordID": larkRecordID},
+	)
+	if err != nil {
+		return nil
+	}
+
+	for _, issue := range issues {
+		recordIDs := map[string]string{}
+		if err := issue.UnmarshalJSONField("lark_record_ids", &recordIDs); err == nil && recordIDs[tableRecordID] == larkRecordID {
+			return issue
+		}
+	}
+	return nil
+}
+
+// getLarkRecordFields 读取飞书记录的当前字段值，人员字段使用 open_id
+func getLarkRecordFields(client *lark.Client, appToken, tableID, larkRecordID string) (map[string]interface{}, error) {
+	req := larkbitable.NewGetAppTableRecordReqBuilder().
+		AppToken(appToken).
+		TableId(tableID).
+		RecordId(larkRecordID).
+		UserIdType("open_id").
+		Build()
+
+	resp, err := client.Bitable.V1.AppTableRecord.Get(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success() {
+		return nil, fmt.Errorf("get record failed, code: %d, msg: %s, requestId: %s", resp.Code, resp.Msg, resp.RequestId())
+	}
+	if resp.Data == nil || resp.Data.Record == nil {
+		return nil, fmt.Errorf("get record returned no record")
+	}
+
+	return resp.Data.Record.Fields, nil
+}
+
+// resolveGitLabUserIDs 将飞书人员 open_id 按映射的 users 配置或 user_mappings 反查为GitLab用户ID
+func resolveGitLabUserIDs(ctx context.Context, app core.App, client *gitlabAPIClient, issue *core.Record, mapping larkFieldMapping, openIDs []string) ([]int, error) {
+	usernames := make(map[string]string, len(mapping.Options.Users))
+	for username, openID := range mapping.Options.Users {
+		usernames[openID] = username
+	}
+
+	// 优先使用Issue中已知的指派人，减少API查询
+	var known []User
+	_ = issue.UnmarshalJSONField("assignees", &known)
+
+	ids := make([]int, 0, len(openIDs))
+	for _, openID := range openIDs {
+		id := 0
+		username, ok := usernames[openID]
+		if !ok {
+			user, found := lookupGitLabUserByOpenID(app, openID)
+			if !found {
+				return nil, fmt.Errorf("lark user %s has no gitlab username mapping", openID)
+			}
+			username, id = user.Username, user.ID
+		}
+
+		for _, user := range known {
+			if id == 0 && user.Username == username {
+				id = user.ID
+			}
+		}
+		if id == 0 {
+			var err error
+			if id, err = client.FindUserID(ctx, username); err != nil {
+				return nil, err
+			}
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// reverseLabel 将飞书选项名按映射的 labels 配置反查为源值，未配置的选项原样返回
+func (m larkFieldMapping) reverseLabel(label string) string {
+	var matched []string
+	for value, mapped := range m.Options.Labels {
+		if mapped == label {
+			matched = append(matched, value)
+		}
+	}
+	if len(matched) == 0 {
+		return label
+	}
+	sort.Strings(matched)
+	return matched[0]
+}
+
+// normalizeLarkValue 将飞书字段值规范化为排序后的文本列表，用于比较：选项取名称，人员取 id
+func normalizeLarkValue(value interface{}) []string {
+	var items []interface{}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		items = v
+	default:
+		items = []interface{}{v}
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		text := ""
+		if object, ok := item.(map[string]interface{}); ok {
+			text, _ = object["id"].(string)
+		} else {
+			text = stringifyValue(item)
+		}
+		if text != "" {
+			values = append(values, text)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
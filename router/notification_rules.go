@@ -0,0 +1,439 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// notification_rules 的通知目标类型
+const (
+	notificationTargetChat    = "chat"
+	notificationTargetUser    = "user"
+	notificationTargetWebhook = "webhook"
+)
+
+// notificationWebhookTimeout 自定义机器人 webhook 请求超时时间
+const notificationWebhookTimeout = 10 * time.Second
+
+// 通知在响应webhook后由后台 worker 发送，避免慢速目标拖慢GitLab的webhook请求
+const (
+	notificationWorkers     = 4                // 发送通知的 worker 数
+	notificationQueueSize   = 256              // 等待发送的事件数上限，队列满时丢弃新的通知
+	notificationSendTimeout = 30 * time.Second // 单个事件全部通知的发送总时长上限
+)
+
+// notificationJob 一个事件匹配到的全部待发送通知
+type notificationJob struct {
+	app     core.App
+	client  *lark.Client
+	event   notificationEvent
+	pending []pendingNotification
+}
+
+// pendingNotification 匹配到的规则及渲染后的消息
+type pendingNotification struct {
+	rule notificationRule
+	text string
+}
+
+var (
+	notificationQueue     = make(chan notificationJob, notificationQueueSize)
+	notificationWorkersUp sync.Once
+)
+
+// defaultNotificationTemplate 规则未配置模板时使用的消息模板
+const defaultNotificationTemplate = `[{{.Namespace}}] {{.EventType}}{{if .Action}} {{.Action}}{{end}}{{if .Title}}
+{{.Title}}{{end}}{{if .Actor}}
+操作人：{{.Actor}}{{end}}{{if .URL}}
+{{.URL}}{{end}}`
+
+// notificationEvent 交给通知规则引擎匹配的事件，由各事件处理器从解析后的事件构造
+type notificationEvent struct {
+	EventType string   // 事件类型，使用 X-Gitlab-Event 的取值，如 Merge Request Hook
+	Action    string   // 事件操作；流水线、作业和部署事件为状态
+	ProjectID int      // 项目ID
+	Namespace string   // 项目完整路径（path_with_namespace）
+	Branch    string   // MR目标分支，推送、流水线、作业和部署的分支
+	Labels    []string // 标签名
+	Author    string   // 作者用户名，规则的 authors 按此匹配；MR为MR作者，其余事件为触发人
+	Actor     string   // 触发人用户名，未设置时与 Author 相同
+	Title     string   // 标题或摘要
+	URL       string   // 事件对象链接
+
+	Data interface{} // 完整事件数据，模板中通过 .Data 访问原始字段
+}
+
+// notificationRule notification_rules 中的一条规则
+type notificationRule struct {
+	ID            string
+	Name          string
+	EventTypes    []string
+	ProjectIDs    []int
+	Namespace     string
+	Branch        string
+	Labels        []string
+	Authors       []string
+	Actions       []string
+	TargetType    string
+	Target        string
+	WebhookSecret string
+	Template      *template.Template
+}
+
+// dispatchNotification 按 notification_rules 中启用的规则路由事件通知
+//
+// 事件依次与每条规则匹配，规则中未配置的条件视为不限；匹配的规则按模板渲染文本消息，
+// 交给后台 worker 发送到飞书群、飞书用户或自定义机器人 webhook。通知失败只记录日志，不影响webhook处理结果。
+func dispatchNotification(e *core.RequestEvent, event notificationEvent, body []byte) {
+	app := e.App
+
+	records, err := app.FindRecordsByFilter("notification_rules", "enabled = true", "", 0, 0, dbx.Params{})
+	if err != nil {
+		app.Logger().Warn("Failed to load notification rules", "error", err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	if event.Actor == "" {
+		event.Actor = event.Author
+	}
+
+	if err := json.Unmarshal(body, &event.Data); err != nil {
+		app.Logger().Warn("Failed to parse event data for notification", "error", err)
+	}
+
+	var pending []pendingNotification
+	for _, record := range records {
+		rule, err := newNotificationRule(record)
+		if err != nil {
+			app.Logger().Warn("Invalid notification rule, skipping", "error", err, "ruleID", record.Id)
+			continue
+		}
+		if !rule.matches(event) {
+			continue
+		}
+
+		var text bytes.Buffer
+		if err := rule.Template.Execute(&text, event); err != nil {
+			app.Logger().Error("Failed to render notification template", "error", err, "ruleID", rule.ID)
+			continue
+		}
+
+		pending = append(pending, pendingNotification{rule: rule, text: text.String()})
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	client, _ := larkClientFromRequest(e)
+	notificationWorkersUp.Do(func() {
+		for i := 0; i < notificationWorkers; i++ {
+			go runNotificationWorker()
+		}
+	})
+
+	select {
+	case notificationQueue <- notificationJob{app: app, client: client, event: event, pending: pending}:
+	default:
+		app.Logger().Error("Notification queue is full, dropping notifications",
+			"eventType", event.EventType,
+			"action", event.Action,
+			"projectID", event.ProjectID,
+			"rules", len(pending),
+		)
+	}
+}
+
+// runNotificationWorker 依次发送队列中的通知
+func runNotificationWorker() {
+	for job := range notificationQueue {
+		sendNotifications(job)
+	}
+}
+
+// sendNotifications 发送一个事件匹配到的全部通知，超过 notificationSendTimeout 后剩余的通知被放弃
+func sendNotifications(job notificationJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationSendTimeout)
+	defer cancel()
+
+	for _, notification := range job.pending {
+		rule := notification.rule
+		if err := sendNotification(ctx, job.client, rule, notification.text); err != nil {
+			job.app.Logger().Error("Failed to send notification",
+				"error", err,
+				"ruleID", rule.ID,
+				"rule", rule.Name,
+				"targetType", rule.TargetType,
+			)
+			continue
+		}
+
+		job.app.Logger().Info("Notification sent",
+			"ruleID", rule.ID,
+			"rule", rule.Name,
+			"eventType", job.event.EventType,
+			"action", job.event.Action,
+			"projectID", job.event.ProjectID,
+			"targetType", rule.TargetType,
+		)
+	}
+}
+
+// newNotificationRule 从 notification_rules 记录构造规则并解析其中的模板
+func newNotificationRule(record *core.Record) (notificationRule, error) {
+	rule := notificationRule{
+		ID:            record.Id,
+		Name:          record.GetString("name"),
+		EventTypes:    record.GetStringSlice("event_types"),
+		Namespace:     record.GetString("namespace"),
+		Branch:        record.GetString("branch"),
+		TargetType:    record.GetString("target_type"),
+		Target:        record.GetString("target"),
+		WebhookSecret: record.GetString("webhook_secret"),
+	}
+
+	for field, target := range map[string]interface{}{
+		"project_ids": &rule.ProjectIDs,
+		"labels":      &rule.Labels,
+		"authors":     &rule.Authors,
+		"actions":     &rule.Actions,
+	} {
+		if err := record.UnmarshalJSONField(field, target); err != nil {
+			return rule, fmt.Errorf("invalid %s: %w", field, err)
+		}
+	}
+
+	text := record.GetString("template")
+	if strings.TrimSpace(text) == "" {
+		text = defaultNotificationTemplate
+	}
+	tmpl, err := template.New(record.Id).Parse(text)
+	if err != nil {
+		return rule, err
+	}
+	rule.Template = tmpl
+
+	return rule, nil
+}
+
+// matches 判断事件是否满足规则的全部条件；项目ID和命名空间同时配置时满足其一即可
+func (r notificationRule) matches(event notificationEvent) bool {
+	if len(r.EventTypes) > 0 && !slices.Contains(r.EventTypes, event.EventType) {
+		return false
+	}
+
+	if len(r.ProjectIDs) > 0 || r.Namespace != "" {
+		byID := slices.Contains(r.ProjectIDs, event.ProjectID)
+		byNamespace := r.Namespace != "" && matchGlob(r.Namespace, event.Namespace)
+		if !byID && !byNamespace {
+			return false
+		}
+	}
+
+	if r.Branch != "" && (event.Branch == "" || !matchGlob(r.Branch, event.Branch)) {
+		return false
+	}
+
+	if len(r.Labels) > 0 && !slices.ContainsFunc(event.Labels, func(label string) bool {
+		return slices.Contains(r.Labels, label)
+	}) {
+		return false
+	}
+
+	if len(r.Authors) > 0 && !slices.Contains(r.Authors, event.Author) {
+		return false
+	}
+
+	if len(r.Actions) > 0 && !slices.Contains(r.Actions, event.Action) {
+		return false
+	}
+
+	return true
+}
+
+// labelTitles 提取标签名
+func labelTitles(labels []Label) []string {
+	titles := make([]string, 0, len(labels))
+	for _, label := range labels {
+		titles = append(titles, label.Title)
+	}
+	return titles
+}
+
+// matchGlob 按 path.Match 规则匹配，* 不跨越 /；以 /** 结尾的模式匹配该前缀下的所有路径
+func matchGlob(pattern, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		if matched, _ := path.Match(prefix, value); matched {
+			return true
+		}
+		for i := len(value) - 1; i > 0; i-- {
+			if value[i] != '/' {
+				continue
+			}
+			if matched, _ := path.Match(prefix, value[:i]); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// sendNotification 按规则的目标类型发送文本消息
+func sendNotification(ctx context.Context, client *lark.Client, rule notificationRule, text string) error {
+	switch rule.TargetType {
+	case notificationTargetChat, notificationTargetUser:
+		if client == nil {
+			return fmt.Errorf("lark app is not configured")
+		}
+		receiveIDType := larkim.ReceiveIdTypeChatId
+		if rule.TargetType == notificationTargetUser {
+			receiveIDType = larkim.ReceiveIdTypeOpenId
+		}
+		return sendLarkText(ctx, client, receiveIDType, rule.Target, text)
+	case notificationTargetWebhook:
+		return sendBotWebhook(ctx, rule.Target, rule.WebhookSecret, text)
+	default:
+		return fmt.Errorf("unknown target type %q", rule.TargetType)
+	}
+}
+
+// sendLarkText 通过飞书应用机器人发送文本消息
+func sendLarkText(ctx context.Context, client *lark.Client, receiveIDType, receiveID, text string) error {
+	content, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req := larkim.NewCreateMessageReqBuilder().
+		ReceiveIdType(receiveIDType).
+		Body(larkim.NewCreateMessageReqBodyBuilder().
+			ReceiveId(receiveID).
+			MsgType(larkim.MsgTypeText).
+			Content(string(content)).
+			Build()).
+		Build()
+
+	resp, err := client.Im.V1.Message.Create(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !resp.Success() {
+		return fmt.Errorf("send message failed, code: %d, msg: %s, requestId: %s", resp.Code, resp.Msg, resp.RequestId())
+	}
+	return nil
+}
+
+// sendBotWebhook 向飞书自定义机器人 webhook 发送文本消息，配置了签名密钥时附带签名
+func sendBotWebhook(ctx context.Context, webhookURL, secret, text string) error {
+	payload := map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]string{"text": text},
+	}
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = timestamp
+		payload["sign"] = botWebhookSign(timestamp, secret)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: notificationWebhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("bot webhook failed, status: %d, body: %s", resp.StatusCode, respBody)
+	}
+
+	// 自定义机器人在 HTTP 200 时通过 code 返回错误
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(respBody, &result); err == nil && result.Code != 0 {
+		return fmt.Errorf("bot webhook failed, code: %d, msg: %s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// botWebhookSign 计算自定义机器人签名：以 timestamp + "\n" + secret 为密钥对空串做 HmacSHA256 后 Base64
+func botWebhookSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// BindNotificationRuleHooks 注册通知规则的校验钩子，保存前检查模板、匹配模式和通知目标
+func BindNotificationRuleHooks(app core.App) {
+	app.OnRecordValidate("notification_rules").BindFunc(func(e *core.RecordEvent) error {
+		if _, err := template.New("").Parse(e.Record.GetString("template")); err != nil {
+			return validation.Errors{"template": validation.NewError("validation_invalid_template", err.Error())}
+		}
+
+		for _, field := range []string{"namespace", "branch"} {
+			pattern := strings.TrimSuffix(e.Record.GetString(field), "/**")
+			if _, err := path.Match(pattern, ""); err != nil {
+				return validation.Errors{field: validation.NewError("validation_invalid_pattern", err.Error())}
+			}
+		}
+
+		for _, field := range []string{"labels", "authors", "actions"} {
+			var values []string
+			if err := e.Record.UnmarshalJSONField(field, &values); err != nil {
+				return validation.Errors{field: validation.NewError("validation_invalid_string_list", "must be an array of strings")}
+			}
+		}
+
+		var projectIDs []int
+		if err := e.Record.UnmarshalJSONField("project_ids", &projectIDs); err != nil {
+			return validation.Errors{"project_ids": validation.NewError("validation_invalid_project_ids", "must be an array of project IDs")}
+		}
+
+		if e.Record.GetString("target_type") == notificationTargetWebhook {
+			target, err := url.Parse(e.Record.GetString("target"))
+			if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+				return validation.Errors{"target": validation.NewError("validation_invalid_webhook_url", "must be an http(s) URL")}
+			}
+		}
+
+		return e.Next()
+	})
+}
@@ -0,0 +1,348 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkcontact "github.com/larksuite/oapi-sdk-go/v3/service/contact/v3"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// user_mappings 的来源
+const (
+	userMappingSourceAuto   = "auto"
+	userMappingSourceManual = "manual"
+)
+
+// larkBatchGetIDLimit 飞书 BatchGetId 单次最多查询的邮箱数
+const larkBatchGetIDLimit = 50
+
+// larkIdentity 飞书用户身份
+type larkIdentity struct {
+	OpenID string
+	UserID string
+}
+
+// prepareUserMapping 按GitLab用户ID、用户名或改名前的用户名查找用户映射，未找到时新建，并写入GitLab用户信息（未保存）
+//
+// 新用户名已被其他GitLab用户的映射占用时（对方已改名但未收到事件），先清空并保存旧映射的用户名。
+func prepareUserMapping(app core.App, user User, oldUsername string) (*core.Record, error) {
+	record := findUserMapping(app, user.ID, user.Username, oldUsername)
+	if record == nil {
+		collection, err := app.FindCollectionByNameOrId("user_mappings")
+		if err != nil {
+			return nil, err
+		}
+		record = core.NewRecord(collection)
+		record.Set("source", userMappingSourceAuto)
+	}
+
+	if err := releaseGitLabUsername(app, record, user.Username); err != nil {
+		return nil, err
+	}
+
+	if user.ID > 0 {
+		record.Set("gitlab_user_id", user.ID)
+	}
+	record.Set("gitlab_username", user.Username)
+	if user.Name != "" {
+		record.Set("gitlab_name", user.Name)
+	}
+	if user.Email != "" {
+		record.Set("email", user.Email)
+	}
+
+	return record, nil
+}
+
+// releaseGitLabUsername 清空其他映射上的 username，使其可以写入 record
+func releaseGitLabUsername(app core.App, record *core.Record, username string) error {
+	if username == "" {
+		return nil
+	}
+
+	other, err := app.FindFirstRecordByData("user_mappings", "gitlab_username", username)
+	if err != nil || other.Id == record.Id {
+		return nil
+	}
+
+	other.Set("gitlab_username", "")
+	if err := app.Save(other); err != nil {
+		return err
+	}
+
+	app.Logger().Info("GitLab username taken over, cleared on previous mapping",
+		"username", username,
+		"previousGitlabUserID", other.GetInt("gitlab_user_id"),
+		"recordID", other.Id,
+	)
+	return nil
+}
+
+// findUserMapping 依次按GitLab用户ID、用户名、改名前的用户名查找用户映射，未找到时返回 nil
+//
+// 按用户名找到的映射属于另一个GitLab用户ID时不视为匹配。
+func findUserMapping(app core.App, userID int, username, oldUsername string) *core.Record {
+	if userID > 0 {
+		if record, err := app.FindFirstRecordByData("user_mappings", "gitlab_user_id", userID); err == nil {
+			return record
+		}
+	}
+	for _, name := range []string{username, oldUsername} {
+		if name == "" {
+			continue
+		}
+		record, err := app.FindFirstRecordByData("user_mappings", "gitlab_username", name)
+		if err != nil {
+			continue
+		}
+		if mappedID := record.GetInt("gitlab_user_id"); userID > 0 && mappedID > 0 && mappedID != userID {
+			continue
+		}
+		return record
+	}
+	return nil
+}
+
+// matchLarkIdentities 按邮箱为自动来源的用户映射匹配飞书用户，手动维护的映射不会被覆盖
+func matchLarkIdentities(client *lark.Client, records []*core.Record) error {
+	emails := make([]string, 0, len(records))
+	for _, record := range records {
+		if record.GetString("source") == userMappingSourceAuto && record.GetString("email") != "" {
+			emails = append(emails, strings.ToLower(record.GetString("email")))
+		}
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+
+	identities, err := resolveLarkUsersByEmail(client, emails)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.GetString("source") != userMappingSourceAuto {
+			continue
+		}
+		identity, ok := identities[strings.ToLower(record.GetString("email"))]
+		if !ok {
+			continue
+		}
+		record.Set("lark_open_id", identity.OpenID)
+		if identity.UserID != "" {
+			record.Set("lark_user_id", identity.UserID)
+		}
+		record.Set("matched_at", types.NowDateTime())
+	}
+
+	return nil
+}
+
+// resolveLarkUsersByEmail 通过飞书通讯录 BatchGetId 按邮箱查询用户的 open_id 和 user_id，返回小写邮箱到身份的映射
+//
+// 查询 user_id 需要额外的通讯录权限，失败时只返回 open_id。
+func resolveLarkUsersByEmail(client *lark.Client, emails []string) (map[string]larkIdentity, error) {
+	ctx := context.Background()
+	identities := make(map[string]larkIdentity, len(emails))
+
+	for start := 0; start < len(emails); start += larkBatchGetIDLimit {
+		chunk := emails[start:min(start+larkBatchGetIDLimit, len(emails))]
+
+		openIDs, err := batchGetLarkIDs(ctx, client, larkcontact.UserIdTypeOpenId, chunk)
+		if err != nil {
+			return nil, err
+		}
+		userIDs, err := batchGetLarkIDs(ctx, client, larkcontact.UserIdTypeUserId, chunk)
+		if err != nil {
+			userIDs = map[string]string{}
+		}
+
+		for email, openID := range openIDs {
+			identities[email] = larkIdentity{OpenID: openID, UserID: userIDs[email]}
+		}
+	}
+
+	return identities, nil
+}
+
+// batchGetLarkIDs 按邮箱查询指定类型的飞书用户ID，未找到或无权限查看的用户不在结果中
+func batchGetLarkIDs(ctx context.Context, client *lark.Client, userIDType string, emails []string) (map[string]string, error) {
+	req := larkcontact.NewBatchGetIdUserReqBuilder().
+		UserIdType(userIDType).
+		Body(larkcontact.NewBatchGetIdUserReqBodyBuilder().
+			Emails(emails).
+			Build()).
+		Build()
+
+	resp, err := client.Contact.V3.User.BatchGetId(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success() {
+		return nil, fmt.Errorf("batch get id failed, code: %d, msg: %s, requestId: %s", resp.Code, resp.Msg, resp.RequestId())
+	}
+
+	ids := map[string]string{}
+	if resp.Data == nil {
+		return ids, nil
+	}
+	for _, user := range resp.Data.UserList {
+		if user == nil || larkcore.StringValue(user.UserId) == "" {
+			continue
+		}
+		ids[strings.ToLower(larkcore.StringValue(user.Email))] = larkcore.StringValue(user.UserId)
+	}
+	return ids, nil
+}
+
+// syncUserMapping 根据GitLab用户系统事件更新用户映射并按邮箱匹配飞书用户，失败只记录日志
+func syncUserMapping(e *core.RequestEvent, user User, oldUsername string) {
+	app := e.App
+
+	record, err := prepareUserMapping(app, user, oldUsername)
+	if err != nil {
+		app.Logger().Error("Failed to prepare user mapping", "error", err, "username", user.Username)
+		return
+	}
+
+	if client, ok := larkClientFromRequest(e); ok {
+		if err := matchLarkIdentities(client, []*core.Record{record}); err != nil {
+			app.Logger().Warn("Failed to match lark user by email", "error", err, "username", user.Username)
+		}
+	}
+
+	if err := app.Save(record); err != nil {
+		app.Logger().Error("Failed to save user mapping", "error", err, "username", user.Username)
+		return
+	}
+
+	app.Logger().Info("User mapping saved",
+		"gitlabUserID", user.ID,
+		"username", user.Username,
+		"oldUsername", oldUsername,
+		"larkMatched", record.GetString("lark_open_id") != "",
+	)
+}
+
+// lookupLarkOpenID 按GitLab用户ID（优先）或用户名查询映射的飞书 open_id，未映射时返回空字符串
+func lookupLarkOpenID(app core.App, userID int, username string) string {
+	if userID <= 0 && username == "" {
+		return ""
+	}
+	record := findUserMapping(app, userID, username, "")
+	if record == nil {
+		return ""
+	}
+	return record.GetString("lark_open_id")
+}
+
+// lookupGitLabUserByOpenID 按飞书 open_id 反查映射的GitLab用户，未映射时返回 false
+func lookupGitLabUserByOpenID(app core.App, openID string) (User, bool) {
+	if openID == "" {
+		return User{}, false
+	}
+	record, err := app.FindFirstRecordByData("user_mappings", "lark_open_id", openID)
+	if err != nil {
+		return User{}, false
+	}
+	return User{
+		ID:       record.GetInt("gitlab_user_id"),
+		Username: record.GetString("gitlab_username"),
+		Name:     record.GetString("gitlab_name"),
+	}, true
+}
+
+// GitLabUserMappingSync 从GitLab用户列表批量更新用户映射并按邮箱匹配飞书用户（仅超级管理员）
+//
+// 读取用户邮箱需要管理员权限的 GITLAB_API_TOKEN，无法读取邮箱的用户只记录GitLab信息。
+func GitLabUserMappingSync(e *core.RequestEvent) error {
+	app := e.App
+
+	larkClient, ok := larkClientFromRequest(e)
+	if !ok {
+		return e.BadRequestError("Missing Lark configuration", nil)
+	}
+	gitlabClient, ok := gitlabAPIClientFromRequest(e)
+	if !ok {
+		return e.BadRequestError("Missing GitLab API token", nil)
+	}
+
+	ctx := e.Request.Context()
+	synced, matched := 0, 0
+
+	for page := 1; ; page++ {
+		users, err := gitlabClient.ListUsers(ctx, page)
+		if err != nil {
+			app.Logger().Error("Failed to list gitlab users", "error", err, "page", page)
+			return e.InternalServerError("Failed to list GitLab users", err)
+		}
+
+		records := make([]*core.Record, 0, len(users))
+		for _, user := range users {
+			record, err := prepareUserMapping(app, user, "")
+			if err != nil {
+				app.Logger().Error("Failed to prepare user mapping", "error", err, "username", user.Username)
+				continue
+			}
+			records = append(records, record)
+		}
+
+		if err := matchLarkIdentities(larkClient, records); err != nil {
+			app.Logger().Error("Failed to match lark users by email", "error", err, "page", page)
+			return e.InternalServerError("Failed to match Lark users", err)
+		}
+
+		for _, record := range records {
+			if err := app.Save(record); err != nil {
+				app.Logger().Error("Failed to save user mapping", "error", err, "username", record.GetString("gitlab_username"))
+				continue
+			}
+			synced++
+			if record.GetString("lark_open_id") != "" {
+				matched++
+			}
+		}
+
+		if len(users) < gitlabUsersPerPage {
+			break
+		}
+	}
+
+	app.Logger().Info("User mappings synced", "synced", synced, "matched", matched)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "User mappings synced",
+		"synced":  synced,
+		"matched": matched,
+	})
+}
+
+// BindUserMappingHooks 注册用户映射的钩子：通过接口或管理后台修改飞书身份的映射标记为手动维护，不再被自动匹配覆盖
+func BindUserMappingHooks(app core.App) {
+	app.OnRecordCreateRequest("user_mappings").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Record.GetString("source") == "" {
+			if e.Record.GetString("lark_open_id") != "" || e.Record.GetString("lark_user_id") != "" {
+				e.Record.Set("source", userMappingSourceManual)
+			} else {
+				e.Record.Set("source", userMappingSourceAuto)
+			}
+		}
+		return e.Next()
+	})
+
+	app.OnRecordUpdateRequest("user_mappings").BindFunc(func(e *core.RecordRequestEvent) error {
+		original := e.Record.Original()
+		if e.Record.GetString("lark_open_id") != original.GetString("lark_open_id") ||
+			e.Record.GetString("lark_user_id") != original.GetString("lark_user_id") {
+			e.Record.Set("source", userMappingSourceManual)
+		}
+		return e.Next()
+	})
+}